@@ -0,0 +1,325 @@
+package attachment
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/karlseguin/typed"
+	"strconv"
+	"strings"
+)
+
+// Types of attachments
+const (
+	PhotoType   = "photo"
+	VideoType   = "video"
+	AudioType   = "audio"
+	DocType     = "doc"
+	WallType    = "wall"
+	MarketType  = "market"
+	PollType    = "poll"
+	StickerType = "sticker"
+	LinkType    = "link"
+)
+
+// Attachment of message
+type Attachment interface {
+	// Type returns vk attachment type
+	Type() string
+
+	// ID returns attachment id for messages.send attachment param,
+	// empty if attachment can not be sent by id
+	ID() string
+}
+
+// Media common fields of media attachments
+type Media struct {
+	ID        int    `json:"id"`
+	OwnerID   int    `json:"owner_id"`
+	AccessKey string `json:"access_key,omitempty"`
+}
+
+func (m Media) format(kind string) string {
+	if m.AccessKey == "" {
+		return fmt.Sprintf("%s%d_%d", kind, m.OwnerID, m.ID)
+	}
+	return fmt.Sprintf("%s%d_%d_%s", kind, m.OwnerID, m.ID, m.AccessKey)
+}
+
+// PhotoSize copy of photo of some size
+type PhotoSize struct {
+	Type   string `json:"type"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Photo photo attachment
+type Photo struct {
+	Media
+	AlbumID int         `json:"album_id"`
+	UserID  int         `json:"user_id"`
+	Text    string      `json:"text"`
+	Date    int         `json:"date"`
+	Sizes   []PhotoSize `json:"sizes"`
+}
+
+// Type returns photo type
+func (p *Photo) Type() string { return PhotoType }
+
+// ID returns photo{owner_id}_{id}[_{access_key}]
+func (p *Photo) ID() string { return p.format(PhotoType) }
+
+// Video video attachment
+type Video struct {
+	Media
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Duration    int    `json:"duration"`
+	Date        int    `json:"date"`
+	Player      string `json:"player"`
+}
+
+// Type returns video type
+func (v *Video) Type() string { return VideoType }
+
+// ID returns video{owner_id}_{id}[_{access_key}]
+func (v *Video) ID() string { return v.format(VideoType) }
+
+// Audio audio attachment
+type Audio struct {
+	Media
+	Artist   string `json:"artist"`
+	Title    string `json:"title"`
+	Duration int    `json:"duration"`
+	URL      string `json:"url"`
+}
+
+// Type returns audio type
+func (a *Audio) Type() string { return AudioType }
+
+// ID returns audio{owner_id}_{id}[_{access_key}]
+func (a *Audio) ID() string { return a.format(AudioType) }
+
+// Doc document attachment
+type Doc struct {
+	Media
+	Title   string `json:"title"`
+	Size    int    `json:"size"`
+	Ext     string `json:"ext"`
+	URL     string `json:"url"`
+	Date    int    `json:"date"`
+	DocType int    `json:"type"`
+}
+
+// Type returns doc type
+func (d *Doc) Type() string { return DocType }
+
+// ID returns doc{owner_id}_{id}[_{access_key}]
+func (d *Doc) ID() string { return d.format(DocType) }
+
+// Wall wall post attachment
+type Wall struct {
+	Media
+	FromID int    `json:"from_id"`
+	ToID   int    `json:"to_id"`
+	Date   int    `json:"date"`
+	Text   string `json:"text"`
+}
+
+// Type returns wall type
+func (w *Wall) Type() string { return WallType }
+
+// ID returns wall{owner_id}_{id}[_{access_key}]
+func (w *Wall) ID() string {
+	m := w.Media
+	if m.OwnerID == 0 {
+		// older api versions return wall owner in to_id
+		m.OwnerID = w.ToID
+	}
+	return m.format(WallType)
+}
+
+// Market market item attachment
+type Market struct {
+	Media
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	ThumbPhoto  string `json:"thumb_photo"`
+}
+
+// Type returns market type
+func (m *Market) Type() string { return MarketType }
+
+// ID returns market{owner_id}_{id}[_{access_key}]
+func (m *Market) ID() string { return m.format(MarketType) }
+
+// Poll poll attachment
+type Poll struct {
+	Media
+	Question  string `json:"question"`
+	Votes     int    `json:"votes"`
+	Anonymous bool   `json:"anonymous"`
+	Multiple  bool   `json:"multiple"`
+}
+
+// Type returns poll type
+func (p *Poll) Type() string { return PollType }
+
+// ID returns poll{owner_id}_{id}[_{access_key}]
+func (p *Poll) ID() string { return p.format(PollType) }
+
+// Sticker sticker attachment, it is sent with sticker_id param
+type Sticker struct {
+	ProductID int `json:"product_id"`
+	StickerID int `json:"sticker_id"`
+}
+
+// Type returns sticker type
+func (s *Sticker) Type() string { return StickerType }
+
+// ID returns empty string, stickers are sent with sticker_id param
+func (s *Sticker) ID() string { return "" }
+
+// Link link attachment, it is sent as url in message text
+type Link struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Caption     string `json:"caption"`
+	Description string `json:"description"`
+	Photo       *Photo `json:"photo"`
+}
+
+// Type returns link type
+func (l *Link) Type() string { return LinkType }
+
+// ID returns empty string, links are sent in message text
+func (l *Link) ID() string { return "" }
+
+// Unknown attachment of type not supported by package
+type Unknown struct {
+	Kind string
+	Data typed.Typed
+}
+
+// Type returns attachment type
+func (u *Unknown) Type() string { return u.Kind }
+
+// ID returns empty string
+func (u *Unknown) ID() string { return "" }
+
+// Parse parses attachment id like photo-123_456_abcdef
+func Parse(s string) (Attachment, error) {
+	i := strings.IndexAny(s, "-0123456789")
+	if i <= 0 {
+		return nil, fmt.Errorf("invalid attachment '%s'", s)
+	}
+	kind := s[:i]
+	parts := strings.SplitN(s[i:], "_", 3)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid attachment '%s'", s)
+	}
+	ownerID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid owner id of attachment '%s': %w", s, err)
+	}
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid id of attachment '%s': %w", s, err)
+	}
+	m := Media{ID: id, OwnerID: ownerID}
+	if len(parts) == 3 {
+		m.AccessKey = parts[2]
+	}
+
+	switch kind {
+	case PhotoType:
+		return &Photo{Media: m}, nil
+	case VideoType:
+		return &Video{Media: m}, nil
+	case AudioType:
+		return &Audio{Media: m}, nil
+	case DocType:
+		return &Doc{Media: m}, nil
+	case WallType:
+		return &Wall{Media: m}, nil
+	case MarketType:
+		return &Market{Media: m}, nil
+	case PollType:
+		return &Poll{Media: m}, nil
+	}
+	return nil, fmt.Errorf("not supported attachment type '%s'", kind)
+}
+
+// FromObject parses attachment object like {"type": "photo", "photo": {...}}
+func FromObject(obj typed.Typed) (Attachment, error) {
+	kind, ok := obj.StringIf("type")
+	if !ok {
+		return nil, fmt.Errorf("attachment invalid 'type' field")
+	}
+	data, ok := obj.ObjectIf(kind)
+	if !ok {
+		return nil, fmt.Errorf("attachment invalid '%s' field", kind)
+	}
+
+	var a Attachment
+	switch kind {
+	case PhotoType:
+		a = &Photo{}
+	case VideoType:
+		a = &Video{}
+	case AudioType:
+		a = &Audio{}
+	case DocType:
+		a = &Doc{}
+	case WallType:
+		a = &Wall{}
+	case MarketType:
+		a = &Market{}
+	case PollType:
+		a = &Poll{}
+	case StickerType:
+		a = &Sticker{}
+	case LinkType:
+		a = &Link{}
+	default:
+		return &Unknown{Kind: kind, Data: data}, nil
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, a); err != nil {
+		return nil, fmt.Errorf("invalid %s attachment: %w", kind, err)
+	}
+	return a, nil
+}
+
+// FromObjects parses attachments array of message
+func FromObjects(objs []typed.Typed) ([]Attachment, error) {
+	res := make([]Attachment, 0, len(objs))
+	for _, obj := range objs {
+		a, err := FromObject(obj)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, a)
+	}
+	return res, nil
+}
+
+// FromMessage parses attachments of message object, e.g. message field of message_new object
+func FromMessage(message typed.Typed) ([]Attachment, error) {
+	return FromObjects(message.Objects("attachments"))
+}
+
+// IDs returns ids of attachments which can be sent by id
+func IDs(attachments []Attachment) []string {
+	ids := make([]string, 0, len(attachments))
+	for _, a := range attachments {
+		if id := a.ID(); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,122 @@
+package attachment
+
+import (
+	"github.com/karlseguin/typed"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	testCases := map[string]Attachment{
+		"photo-123_456_abcdef": &Photo{Media: Media{ID: 456, OwnerID: -123, AccessKey: "abcdef"}},
+		"doc1_2":               &Doc{Media: Media{ID: 2, OwnerID: 1}},
+		"video3_4":             &Video{Media: Media{ID: 4, OwnerID: 3}},
+		"audio5_6":             &Audio{Media: Media{ID: 6, OwnerID: 5}},
+		"wall-7_8":             &Wall{Media: Media{ID: 8, OwnerID: -7}},
+		"market-9_10":          &Market{Media: Media{ID: 10, OwnerID: -9}},
+		"poll11_12_key":        &Poll{Media: Media{ID: 12, OwnerID: 11, AccessKey: "key"}},
+	}
+	for s, expected := range testCases {
+		a, err := Parse(s)
+		if err != nil {
+			t.Errorf("%s: should not be error: %v", s, err)
+			continue
+		}
+		if !reflect.DeepEqual(a, expected) {
+			t.Errorf("%s: expected %+v, got %+v", s, expected, a)
+		}
+		if a.ID() != s {
+			t.Errorf("%s: formatted back to %s", s, a.ID())
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	invalid := []string{"", "photo", "-1_2", "photo1", "photox_2", "photo1_x", "sticker1_2", "unknown1_2"}
+	for _, s := range invalid {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("%s: should be error", s)
+		}
+	}
+}
+
+func TestFromMessage(t *testing.T) {
+	message := typed.Typed{
+		"text": "test",
+		"attachments": []interface{}{
+			map[string]interface{}{
+				"type": "photo",
+				"photo": map[string]interface{}{
+					"id": 1, "owner_id": -2, "access_key": "key",
+					"sizes": []interface{}{map[string]interface{}{"type": "x", "url": "https://test/x.jpg", "width": 604}},
+				},
+			},
+			map[string]interface{}{
+				"type": "doc",
+				"doc":  map[string]interface{}{"id": 3, "owner_id": 4, "title": "test.txt", "ext": "txt", "type": 1},
+			},
+			map[string]interface{}{
+				"type":    "sticker",
+				"sticker": map[string]interface{}{"product_id": 5, "sticker_id": 6},
+			},
+			map[string]interface{}{
+				"type": "link",
+				"link": map[string]interface{}{"url": "https://vk.com", "title": "VK"},
+			},
+			map[string]interface{}{
+				"type": "wall",
+				"wall": map[string]interface{}{"id": 7, "to_id": -8, "from_id": -8},
+			},
+			map[string]interface{}{
+				"type":  "story",
+				"story": map[string]interface{}{"id": 9},
+			},
+		},
+	}
+
+	attachments, err := FromMessage(message)
+	if err != nil {
+		t.Fatal("should not be error", err)
+	}
+	if len(attachments) != 6 {
+		t.Fatalf("should be 6 attachments, got %d", len(attachments))
+	}
+
+	photo, ok := attachments[0].(*Photo)
+	if !ok || len(photo.Sizes) != 1 || photo.Sizes[0].Width != 604 {
+		t.Errorf("invalid photo %+v", attachments[0])
+	}
+	doc, ok := attachments[1].(*Doc)
+	if !ok || doc.Title != "test.txt" || doc.DocType != 1 {
+		t.Errorf("invalid doc %+v", attachments[1])
+	}
+	sticker, ok := attachments[2].(*Sticker)
+	if !ok || sticker.StickerID != 6 {
+		t.Errorf("invalid sticker %+v", attachments[2])
+	}
+	link, ok := attachments[3].(*Link)
+	if !ok || link.URL != "https://vk.com" {
+		t.Errorf("invalid link %+v", attachments[3])
+	}
+	if u, ok := attachments[5].(*Unknown); !ok || u.Type() != "story" {
+		t.Errorf("invalid unknown attachment %+v", attachments[5])
+	}
+
+	expected := []string{"photo-2_1_key", "doc4_3", "wall-8_7"}
+	if ids := IDs(attachments); !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected ids %v, got %v", expected, ids)
+	}
+}
+
+func TestFromObjectInvalid(t *testing.T) {
+	invalid := []typed.Typed{
+		{},
+		{"type": "photo"},
+		{"type": "photo", "photo": map[string]interface{}{"id": "not a number"}},
+	}
+	for _, obj := range invalid {
+		if _, err := FromObject(obj); err == nil {
+			t.Error("should be error", obj)
+		}
+	}
+}
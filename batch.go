@@ -0,0 +1,275 @@
+package vkbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/karlseguin/typed"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxBatchSize max number of api calls in one execute request
+const MaxBatchSize = 25
+
+var methodNameRegexp = regexp.MustCompile(`^[a-zA-Z]+\.[a-zA-Z]+$`)
+
+// serviceParams params added by VkAPI to every request, they are not passed into execute code
+var serviceParams = map[string]bool{"v": true, "lang": true, "access_token": true}
+
+// executor is implemented by VkAPI returning whole response body with 'execute_errors'
+type executor interface {
+	call(ctx context.Context, methodName string, params Params) (*apiResponse, error)
+
+	// retryable reports whether call failed with err may be retried according to retry policy
+	retryable(methodName string, params Params, err error) bool
+}
+
+// BatchCall single api call of Batch
+type BatchCall struct {
+	Method string
+	Params Params
+
+	resp typed.Typed
+	err  error
+}
+
+// Response returns result of call after Batch executed
+// Not object responses are wrapped into 'response' field as CallMethod does
+func (c *BatchCall) Response() (typed.Typed, error) {
+	return c.resp, c.err
+}
+
+// Batch collects up to MaxBatchSize api calls to send them in one execute request
+type Batch struct {
+	api   VkAPI
+	calls []*BatchCall
+}
+
+// NewBatch creates new empty Batch
+func NewBatch(api VkAPI) *Batch {
+	return &Batch{api: api}
+}
+
+// Add adds method call to batch
+func (b *Batch) Add(methodName string, params Params) (*BatchCall, error) {
+	if len(b.calls) >= MaxBatchSize {
+		return nil, ErrBatchFull
+	}
+	if !methodNameRegexp.MatchString(methodName) {
+		return nil, fmt.Errorf("invalid method name '%s'", methodName)
+	}
+	c := &BatchCall{Method: methodName, Params: params}
+	b.calls = append(b.calls, c)
+	return c, nil
+}
+
+// Len returns number of calls in batch
+func (b *Batch) Len() int {
+	return len(b.calls)
+}
+
+// Code compiles calls to VKScript code for execute method
+func (b *Batch) Code() (string, error) {
+	calls := make([]string, 0, len(b.calls))
+	for _, c := range b.calls {
+		values, err := c.Params.Values()
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", c.Method, err)
+		}
+		args := make(map[string]string, len(values))
+		for k, v := range values {
+			if serviceParams[k] {
+				continue
+			}
+			args[k] = v[0]
+		}
+		data, err := json.Marshal(args)
+		if err != nil {
+			return "", err
+		}
+		calls = append(calls, fmt.Sprintf("API.%s(%s)", c.Method, data))
+	}
+	return fmt.Sprintf("return [%s];", strings.Join(calls, ",")), nil
+}
+
+// Execute sends calls in one execute request and fills results of every call
+// Returned error means the whole request failed, errors of single calls are available via BatchCall.Response
+// execute is not retried, calls failed with retryable errors are repeated one by one according to retry policy
+func (b *Batch) Execute() error {
+	return b.ExecuteContext(context.Background())
+}
+
+// ExecuteContext same as Execute, request is cancelled when ctx is done
+func (b *Batch) ExecuteContext(ctx context.Context) error {
+	if len(b.calls) == 0 {
+		return nil
+	}
+	e, ok := b.api.(executor)
+	if !ok {
+		// VkAPI does not expose execute_errors, so call methods one by one
+		for _, c := range b.calls {
+			c.resp, c.err = b.api.CallMethodContext(ctx, c.Method, c.Params)
+		}
+		return nil
+	}
+
+	code, err := b.Code()
+	if err != nil {
+		b.fail(err)
+		return err
+	}
+	resp, err := e.call(ctx, "execute", Params{"code": code})
+	if err != nil {
+		b.fail(err)
+		return err
+	}
+	b.split(resp.data)
+	b.retry(ctx, e)
+	return nil
+}
+
+// retry repeats calls failed with retryable errors one by one
+func (b *Batch) retry(ctx context.Context, e executor) {
+	for _, c := range b.calls {
+		if c.err != nil && e.retryable(c.Method, c.Params, c.err) {
+			c.resp, c.err = b.api.CallMethodContext(ctx, c.Method, c.Params)
+		}
+	}
+}
+
+func (b *Batch) fail(err error) {
+	for _, c := range b.calls {
+		c.resp, c.err = nil, err
+	}
+}
+
+// split distributes execute response between calls,
+// failed calls return false and take next error of 'execute_errors' with the same method in order,
+// false result without such error is result of call
+func (b *Batch) split(data typed.Typed) {
+	results, ok := data["response"].([]interface{})
+	if !ok || len(results) != len(b.calls) {
+		err := newInternalError(fmt.Errorf("execute response is not an array of %d results", len(b.calls)), "method called execute")
+		err.Misc["resp"] = data
+		b.fail(err)
+		return
+	}
+	execErrors := data.Objects("execute_errors")
+	for i, c := range b.calls {
+		r := results[i]
+		if ok, isBool := r.(bool); isBool && !ok {
+			if j := nextExecuteError(execErrors, c.Method); j >= 0 {
+				c.err = newAPIError(c.Method, execErrors[j])
+				execErrors = append(execErrors[:j:j], execErrors[j+1:]...)
+				continue
+			}
+		}
+		if obj, ok := r.(map[string]interface{}); ok {
+			c.resp = obj
+			continue
+		}
+		c.resp = typed.Typed{"response": r}
+	}
+}
+
+// nextExecuteError returns index of the first execute error of method, -1 if there is no one
+func nextExecuteError(execErrors []typed.Typed, method string) int {
+	for i, e := range execErrors {
+		if m := e.String("method"); m == "" || m == method {
+			return i
+		}
+	}
+	return -1
+}
+
+// AutoBatcher VkAPI merging calls made within short window into execute requests
+type AutoBatcher struct {
+	api     VkAPI
+	window  time.Duration
+	pending *pendingBatch
+	mtx     *sync.Mutex
+}
+
+type pendingBatch struct {
+	batch *Batch
+	timer *time.Timer
+	done  chan struct{}
+}
+
+// NewAutoBatcher creates AutoBatcher which waits window for other calls before sending execute request
+func NewAutoBatcher(api VkAPI, window time.Duration) *AutoBatcher {
+	return &AutoBatcher{
+		api:    api,
+		window: window,
+		mtx:    &sync.Mutex{},
+	}
+}
+
+// SetConfig set configuration of underlying VkAPI
+func (a *AutoBatcher) SetConfig(config VkAPIConfig) {
+	a.api.SetConfig(config)
+}
+
+// CallMethod adds call to pending batch and waits for its result
+func (a *AutoBatcher) CallMethod(methodName string, params Params) (typed.Typed, error) {
+	return a.CallMethodContext(context.Background(), methodName, params)
+}
+
+// CallMethodContext adds call to pending batch and waits for its result until ctx is done,
+// cancellation does not remove call from already pending batch
+func (a *AutoBatcher) CallMethodContext(ctx context.Context, methodName string, params Params) (typed.Typed, error) {
+	if methodName == "execute" {
+		return a.api.CallMethodContext(ctx, methodName, params)
+	}
+
+	a.mtx.Lock()
+	p := a.pending
+	if p == nil {
+		p = &pendingBatch{batch: NewBatch(a.api), done: make(chan struct{})}
+		p.timer = time.AfterFunc(a.window, func() { a.flush(p) })
+		a.pending = p
+	}
+	c, err := p.batch.Add(methodName, params)
+	if err != nil {
+		a.mtx.Unlock()
+		return a.api.CallMethodContext(ctx, methodName, params)
+	}
+	if p.batch.Len() == MaxBatchSize {
+		a.pending = nil
+		p.timer.Stop()
+		go a.execute(p)
+	}
+	a.mtx.Unlock()
+
+	select {
+	case <-p.done:
+		return c.Response()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (a *AutoBatcher) flush(p *pendingBatch) {
+	a.mtx.Lock()
+	if a.pending != p {
+		// already flushed because of size
+		a.mtx.Unlock()
+		return
+	}
+	a.pending = nil
+	a.mtx.Unlock()
+	a.execute(p)
+}
+
+func (a *AutoBatcher) execute(p *pendingBatch) {
+	defer close(p.done)
+	if p.batch.Len() == 1 {
+		c := p.batch.calls[0]
+		c.resp, c.err = a.api.CallMethod(c.Method, c.Params)
+		return
+	}
+	p.batch.Execute()
+}
@@ -0,0 +1,214 @@
+package vkbot
+
+import (
+	"errors"
+	"github.com/karlseguin/typed"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBatch_Add(t *testing.T) {
+	b := NewBatch(nil)
+	if _, err := b.Add("return 1; API.users", Params{}); err == nil {
+		t.Error("should be error for invalid method name")
+	}
+	for i := 0; i < MaxBatchSize; i++ {
+		if _, err := b.Add("users.get", Params{}); err != nil {
+			t.Fatal("should not be error", err)
+		}
+	}
+	if _, err := b.Add("users.get", Params{}); !errors.Is(err, ErrBatchFull) {
+		t.Error("should be ErrBatchFull", err)
+	}
+	if b.Len() != MaxBatchSize {
+		t.Errorf("should be %d calls, got %d", MaxBatchSize, b.Len())
+	}
+}
+
+func TestBatch_Code(t *testing.T) {
+	b := NewBatch(nil)
+	b.Add("users.get", Params{"user_ids": "1,2", "access_token": "secret"})
+	b.Add("messages.send", Params{"peer_id": 1, "message": `say "hi"`})
+	code, err := b.Code()
+	if err != nil {
+		t.Fatal("should not be error", err)
+	}
+	expected := `return [API.users.get({"user_ids":"1,2"}),API.messages.send({"message":"say \"hi\"","peer_id":"1"})];`
+	if code != expected {
+		t.Errorf("invalid code:\n%s\nexpected:\n%s", code, expected)
+	}
+}
+
+func TestBatch_Execute(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.HasSuffix(r.URL.Path, "/execute") || r.FormValue("code") == "" {
+				w.Write([]byte(`{"error": {"error_code": 8, "error_msg": "Invalid request"}}`))
+				return
+			}
+			w.Write([]byte(`{"response": [{"count": 1}, false, 5],
+				"execute_errors": [{"method": "messages.send", "error_code": 901, "error_msg": "Can't send"}]}`))
+		}))
+	defer server.Close()
+
+	api := &vkAPI{
+		URL:    server.URL + "/",
+		client: server.Client(),
+	}
+	b := NewBatch(api)
+	first, _ := b.Add("messages.getConversations", Params{})
+	second, _ := b.Add("messages.send", Params{"peer_id": 1})
+	third, _ := b.Add("messages.send", Params{"peer_id": 2})
+	if err := b.Execute(); err != nil {
+		t.Fatal("should not be error", err)
+	}
+
+	if resp, err := first.Response(); err != nil || resp.Int("count") != 1 {
+		t.Error("invalid first result", resp, err)
+	}
+	if _, err := second.Response(); !IsErrorCode(err, ErrorCodeMessagesDenySend) {
+		t.Error("second call should fail with execute error", err)
+	}
+	if resp, err := third.Response(); err != nil || resp.Int("response") != 5 {
+		t.Error("invalid third result", resp, err)
+	}
+}
+
+func TestBatch_ExecuteFalseResult(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"response": [false, false],
+				"execute_errors": [{"method": "messages.send", "error_code": 901, "error_msg": "Can't send"}]}`))
+		}))
+	defer server.Close()
+
+	api := &vkAPI{
+		URL:    server.URL + "/",
+		client: server.Client(),
+	}
+	b := NewBatch(api)
+	first, _ := b.Add("groups.isMember", Params{"group_id": 1})
+	second, _ := b.Add("messages.send", Params{"peer_id": 1})
+	if err := b.Execute(); err != nil {
+		t.Fatal("should not be error", err)
+	}
+	if resp, err := first.Response(); err != nil || resp.Bool("response") {
+		t.Error("false should be result of call without execute error", resp, err)
+	}
+	if _, err := second.Response(); !IsErrorCode(err, ErrorCodeMessagesDenySend) {
+		t.Error("second call should fail with execute error", err)
+	}
+}
+
+func TestBatch_ExecuteRetriesCalls(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/execute") {
+				w.Write([]byte(`{"response": [false, false], "execute_errors": [
+					{"method": "users.get", "error_code": 6, "error_msg": "Too many requests per second"},
+					{"method": "messages.send", "error_code": 6, "error_msg": "Too many requests per second"}]}`))
+				return
+			}
+			atomic.AddInt32(&calls, 1)
+			w.Write([]byte(`{"response": {"id": 1}}`))
+		}))
+	defer server.Close()
+
+	api := &vkAPI{
+		URL:    server.URL + "/",
+		client: server.Client(),
+	}
+	api.SetConfig(VkAPIConfig{
+		Retry: RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+	})
+	b := NewBatch(api)
+	first, _ := b.Add("users.get", Params{})
+	second, _ := b.Add("messages.send", Params{"peer_id": 1})
+	if err := b.Execute(); err != nil {
+		t.Fatal("should not be error", err)
+	}
+	if resp, err := first.Response(); err != nil || resp.Int("id") != 1 {
+		t.Error("retryable call should be repeated", resp, err)
+	}
+	if _, err := second.Response(); !IsErrorCode(err, ErrorCodeTooManyRequests) {
+		t.Error("not idempotent call should not be repeated", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("should be 1 repeated call, got %d", n)
+	}
+}
+
+func TestBatch_ExecuteFailed(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"error": {"error_code": 5, "error_msg": "User authorization failed"}}`))
+		}))
+	defer server.Close()
+
+	api := &vkAPI{
+		URL:    server.URL + "/",
+		client: server.Client(),
+	}
+	b := NewBatch(api)
+	c, _ := b.Add("users.get", Params{})
+	if err := b.Execute(); !IsErrorCode(err, ErrorCodeAuthFailed) {
+		t.Error("should be auth error", err)
+	}
+	if _, err := c.Response(); !IsErrorCode(err, ErrorCodeAuthFailed) {
+		t.Error("call should share batch error", err)
+	}
+}
+
+func TestBatch_ExecuteFallback(t *testing.T) {
+	api := newFakeVkAPI(map[string]typed.Typed{"users.get": {"id": 1}})
+	b := NewBatch(api)
+	first, _ := b.Add("users.get", Params{})
+	second, _ := b.Add("groups.get", Params{})
+	if err := b.Execute(); err != nil {
+		t.Fatal("should not be error", err)
+	}
+	if resp, err := first.Response(); err != nil || resp.Int("id") != 1 {
+		t.Error("invalid first result", resp, err)
+	}
+	if _, err := second.Response(); err == nil {
+		t.Error("second call should fail")
+	}
+}
+
+func TestAutoBatcher(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&requests, 1)
+			w.Write([]byte(`{"response": [1, 1, 1]}`))
+		}))
+	defer server.Close()
+
+	api := &vkAPI{
+		URL:    server.URL + "/",
+		client: server.Client(),
+	}
+	a := NewAutoBatcher(api, 20*time.Millisecond)
+
+	wg := &sync.WaitGroup{}
+	wg.Add(3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			defer wg.Done()
+			resp, err := a.CallMethod("messages.markAsRead", Params{"peer_id": 1})
+			if err != nil || resp.Int("response") != 1 {
+				t.Error("invalid result", resp, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("calls should be merged into 1 request, got %d", n)
+	}
+}
@@ -0,0 +1,59 @@
+package vkbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// rawCaller is implemented by VkAPI able to return not decoded 'response' field
+type rawCaller interface {
+	callRaw(ctx context.Context, methodName string, params Params) (json.RawMessage, error)
+}
+
+// Call calls method by name with params and decodes 'response' field into T
+//
+// T may be any type json can be decoded into, so number, array and object responses are supported:
+//
+//	users, err := vkbot.Call[[]User](ctx, api, "users.get", vkbot.Params{"user_ids": []int{1}})
+func Call[T any](ctx context.Context, api VkAPI, methodName string, params Params) (T, error) {
+	var res T
+	resp, err := CallResponse(ctx, api, methodName, params)
+	if err != nil {
+		return res, err
+	}
+	if err := resp.Decode(&res); err != nil {
+		ierr := newInternalError(err, "method called %s", methodName)
+		ierr.Misc["response"] = string(resp.Raw())
+		var zero T
+		return zero, ierr
+	}
+	return res, nil
+}
+
+func isObjectType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct || t.Kind() == reflect.Map
+}
+
+func callRaw(ctx context.Context, api VkAPI, methodName string, params Params) (json.RawMessage, error) {
+	if rc, ok := api.(rawCaller); ok {
+		return rc.callRaw(ctx, methodName, params)
+	}
+	resp, err := api.CallMethodContext(ctx, methodName, params)
+	if err != nil {
+		return nil, err
+	}
+	// CallMethod returns number and array responses in 'response' field
+	if v, ok := resp["response"]; ok && len(resp) == 1 {
+		return json.Marshal(v)
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return nil, fmt.Errorf("can not marshal response of %s: %w", methodName, err)
+	}
+	return data, nil
+}
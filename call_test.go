@@ -0,0 +1,87 @@
+package vkbot
+
+import (
+	"context"
+	"github.com/karlseguin/typed"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type testUser struct {
+	ID        int    `json:"id"`
+	FirstName string `json:"first_name"`
+}
+
+func newResponseServer(body string) *httptest.Server {
+	return httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+}
+
+func TestCall(t *testing.T) {
+	server := newResponseServer(`{"response": [{"id": 1, "first_name": "Pavel"}, {"id": 2, "first_name": "Nikolai"}]}`)
+	defer server.Close()
+	api := &vkAPI{URL: server.URL + "/", client: server.Client()}
+
+	users, err := Call[[]testUser](context.Background(), api, "users.get", Params{"user_ids": []int{1, 2}})
+	if err != nil {
+		t.Fatal("should not be error", err)
+	}
+	expected := []testUser{{ID: 1, FirstName: "Pavel"}, {ID: 2, FirstName: "Nikolai"}}
+	if !reflect.DeepEqual(users, expected) {
+		t.Errorf("expected %v, got %v", expected, users)
+	}
+}
+
+func TestCallShapes(t *testing.T) {
+	server := newResponseServer(`{"response": 42}`)
+	defer server.Close()
+	api := &vkAPI{URL: server.URL + "/", client: server.Client()}
+	if n, err := Call[int](context.Background(), api, "messages.send", Params{}); err != nil || n != 42 {
+		t.Error("invalid number response", n, err)
+	}
+
+	server = newResponseServer(`{"response": {"id": 3, "first_name": "Ivan"}}`)
+	defer server.Close()
+	api = &vkAPI{URL: server.URL + "/", client: server.Client()}
+	if u, err := Call[testUser](context.Background(), api, "test.Tests", Params{}); err != nil || u.ID != 3 {
+		t.Error("invalid object response", u, err)
+	}
+
+	server = newResponseServer(`{"response": []}`)
+	defer server.Close()
+	api = &vkAPI{URL: server.URL + "/", client: server.Client()}
+	if u, err := Call[testUser](context.Background(), api, "test.Tests", Params{}); err != nil || u.ID != 0 {
+		t.Error("empty array should be decoded as empty object", u, err)
+	}
+	if _, err := Call[int](context.Background(), api, "test.Tests", Params{}); err == nil {
+		t.Error("should be error while decoding array into int")
+	}
+}
+
+func TestCallError(t *testing.T) {
+	server := newResponseServer(`{"error": {"error_code": 15, "error_msg": "Access denied"}}`)
+	defer server.Close()
+	api := &vkAPI{URL: server.URL + "/", client: server.Client()}
+	if _, err := Call[testUser](context.Background(), api, "test.Tests", Params{}); !IsErrorCode(err, ErrorCodeAccessDenied) {
+		t.Error("should be access denied error", err)
+	}
+}
+
+func TestCallFallback(t *testing.T) {
+	api := newFakeVkAPI(map[string]typed.Typed{
+		"users.get":     {"response": []interface{}{map[string]interface{}{"id": 1, "first_name": "Pavel"}}},
+		"groups.getOne": {"id": 1, "first_name": "Pavel"},
+	})
+	users, err := Call[[]testUser](context.Background(), api, "users.get", Params{})
+	if err != nil || len(users) != 1 || users[0].FirstName != "Pavel" {
+		t.Error("invalid array response", users, err)
+	}
+	u, err := Call[testUser](context.Background(), api, "groups.getOne", Params{})
+	if err != nil || u.ID != 1 {
+		t.Error("invalid object response", u, err)
+	}
+}
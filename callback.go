@@ -0,0 +1,100 @@
+package vkbot
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/AndrewShukhtin/vkbot/event"
+	"time"
+)
+
+// Event data types of messages.sendMessageEventAnswer
+const (
+	ShowSnackbarType = "show_snackbar"
+	OpenLinkType     = "open_link"
+	OpenAppType      = "open_app"
+)
+
+// EventData action performed by user's client after callback button click is answered
+type EventData interface {
+	// EventDataType returns type of action, e.g. show_snackbar
+	EventDataType() string
+}
+
+// ShowSnackbar shows text in snackbar
+type ShowSnackbar struct {
+	// Text of snackbar, up to 90 characters
+	Text string `json:"text"`
+}
+
+// OpenLink opens link
+type OpenLink struct {
+	Link string `json:"link"`
+}
+
+// OpenApp opens VK Mini App
+type OpenApp struct {
+	AppID   int    `json:"app_id"`
+	OwnerID int    `json:"owner_id,omitempty"`
+	Hash    string `json:"hash,omitempty"`
+}
+
+// EventDataType returns show_snackbar
+func (d ShowSnackbar) EventDataType() string {
+	return ShowSnackbarType
+}
+
+// EventDataType returns open_link
+func (d OpenLink) EventDataType() string {
+	return OpenLinkType
+}
+
+// EventDataType returns open_app
+func (d OpenApp) EventDataType() string {
+	return OpenAppType
+}
+
+// MarshalJSON adds type field to event data
+func (d ShowSnackbar) MarshalJSON() ([]byte, error) {
+	type data ShowSnackbar
+	return marshalEventData(d, data(d))
+}
+
+// MarshalJSON adds type field to event data
+func (d OpenLink) MarshalJSON() ([]byte, error) {
+	type data OpenLink
+	return marshalEventData(d, data(d))
+}
+
+// MarshalJSON adds type field to event data
+func (d OpenApp) MarshalJSON() ([]byte, error) {
+	type data OpenApp
+	return marshalEventData(d, data(d))
+}
+
+// marshalEventData marshals fields of event data, v is d converted to type without MarshalJSON
+func marshalEventData(d EventData, v interface{}) ([]byte, error) {
+	fields, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var res map[string]interface{}
+	if err := json.Unmarshal(fields, &res); err != nil {
+		return nil, err
+	}
+	res["type"] = d.EventDataType()
+	return json.Marshal(res)
+}
+
+// autoAnswerTimeout max duration of automatic answer to callback button click
+const autoAnswerTimeout = 3 * time.Second
+
+// autoAnswer answers callback button click of message_event event if handler did not answer it,
+// it uses bot context, so answer is sent even if handler overran its timeout
+func (bot *VkBot) autoAnswer(c *Context) error {
+	if c.Type() != event.MessageEventType || c.answered {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(bot.baseContext(), autoAnswerTimeout)
+	defer cancel()
+	return c.answerCallback(ctx, nil)
+}
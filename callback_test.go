@@ -0,0 +1,147 @@
+package vkbot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/AndrewShukhtin/vkbot/event"
+	"github.com/karlseguin/typed"
+	"testing"
+)
+
+func TestEventData_MarshalJSON(t *testing.T) {
+	testCases := []struct {
+		data     EventData
+		expected string
+	}{
+		{ShowSnackbar{Text: "done"}, `{"text":"done","type":"show_snackbar"}`},
+		{OpenLink{Link: "https://vk.com"}, `{"link":"https://vk.com","type":"open_link"}`},
+		{OpenApp{AppID: 1, Hash: "h"}, `{"app_id":1,"hash":"h","type":"open_app"}`},
+		{OpenApp{AppID: 1, OwnerID: -2}, `{"app_id":1,"owner_id":-2,"type":"open_app"}`},
+	}
+	for _, tc := range testCases {
+		data, err := json.Marshal(tc.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tc.expected {
+			t.Errorf("expected %s, got %s", tc.expected, data)
+		}
+	}
+}
+
+func TestMessages_SendMessageEventAnswer(t *testing.T) {
+	api := &recordingVkAPI{resp: typed.Typed{"response": 1}}
+	err := NewMessages(api).SendMessageEventAnswer(context.Background(), MessageEventAnswerParams{
+		EventID: "callback_id",
+		UserID:  1,
+		PeerID:  2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkParams(t, api.lastCall(), "messages.sendMessageEventAnswer", map[string]string{
+		"event_id": "callback_id",
+		"user_id":  "1",
+		"peer_id":  "2",
+	})
+	if _, ok := api.lastCall().Params["event_data"]; ok {
+		t.Error("should not be event_data in empty answer")
+	}
+}
+
+func newAutoAnswerBot(api VkAPI, handler HandleFunc) *VkBot {
+	bot := &VkBot{
+		vkAPI:    api,
+		handlers: map[string]HandleFunc{},
+		config:   BotConfig{AutoAnswerCallbacks: true},
+	}
+	bot.EventHandler(event.MessageEventType, handler)
+	bot.EventHandler(event.MessageNewType, handler)
+	bot.buildChains()
+	return bot
+}
+
+func TestVkBot_AutoAnswerCallbacks(t *testing.T) {
+	api := &recordingVkAPI{resp: typed.Typed{"response": 1}}
+	bot := newAutoAnswerBot(api, func(_ *Context) error {
+		return errors.New("handler error")
+	})
+	var reported error
+	bot.OnError(func(_ *Context, err error) {
+		reported = err
+	})
+	bot.handleEvent(newMessageEventReplyContext(t, nil).Event)
+	if len(api.calls) != 1 {
+		t.Fatal("should be answered not answered callback", api.calls)
+	}
+	checkParams(t, api.lastCall(), "messages.sendMessageEventAnswer", map[string]string{
+		"event_id": "callback_id",
+		"user_id":  "3",
+		"peer_id":  "3",
+	})
+	if reported == nil || reported.Error() != "handler error" {
+		t.Error("should be reported handler error", reported)
+	}
+
+	api.calls = nil
+	bot.handleEvent(newMessageNewReplyContext(t, nil).Event)
+	if len(api.calls) != 0 {
+		t.Error("should not be answered not callback event", api.calls)
+	}
+}
+
+func TestVkBot_AutoAnswerAnsweredCallback(t *testing.T) {
+	api := &recordingVkAPI{resp: typed.Typed{"response": 1}}
+	bot := newAutoAnswerBot(api, func(c *Context) error {
+		return c.AnswerCallback(OpenLink{Link: "https://vk.com"})
+	})
+	bot.handleEvent(newMessageEventReplyContext(t, nil).Event)
+	if len(api.calls) != 1 {
+		t.Fatal("should be answered once", api.calls)
+	}
+	checkParams(t, api.lastCall(), "messages.sendMessageEventAnswer", map[string]string{
+		"event_data": `{"link":"https://vk.com","type":"open_link"}`,
+	})
+
+	api.calls = nil
+	bot.config.AutoAnswerCallbacks = false
+	bot.EventHandler(event.MessageEventType, func(_ *Context) error { return nil })
+	bot.buildChains()
+	bot.handleEvent(newMessageEventReplyContext(t, nil).Event)
+	if len(api.calls) != 0 {
+		t.Error("should not be answered without option", api.calls)
+	}
+}
+
+// deadlineVkAPI records whether calls have deadline
+type deadlineVkAPI struct {
+	recordingVkAPI
+	hasDeadline bool
+}
+
+func (api *deadlineVkAPI) CallMethodContext(ctx context.Context, methodName string, params Params) (typed.Typed, error) {
+	_, api.hasDeadline = ctx.Deadline()
+	return api.recordingVkAPI.CallMethodContext(ctx, methodName, params)
+}
+
+func TestVkBot_AutoAnswerPanickedHandler(t *testing.T) {
+	api := &deadlineVkAPI{recordingVkAPI: recordingVkAPI{resp: typed.Typed{"response": 1}}}
+	bot := newAutoAnswerBot(api, func(_ *Context) error {
+		panic("handler panic")
+	})
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("should be propagated panic to worker")
+			}
+		}()
+		bot.handleEvent(newMessageEventReplyContext(t, nil).Event)
+	}()
+	if len(api.calls) != 1 || api.lastCall().MethodName != "messages.sendMessageEventAnswer" {
+		t.Fatal("should be answered callback of panicked handler", api.calls)
+	}
+	if !api.hasDeadline {
+		t.Error("should be answered with timeout")
+	}
+}
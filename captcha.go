@@ -0,0 +1,88 @@
+package vkbot
+
+import (
+	"context"
+	"time"
+)
+
+// maxCaptchaAttempts max number of captchas solved for one call
+const maxCaptchaAttempts = 3
+
+// CaptchaSolver solves captcha required by vk api (error 14)
+type CaptchaSolver interface {
+	// Solve returns captcha_key for captcha with captcha_sid and captcha_img url
+	Solve(ctx context.Context, sid string, img string) (string, error)
+}
+
+type failFastCaptchaSolver struct{}
+
+// NewFailFastCaptchaSolver creates CaptchaSolver which never solves captcha,
+// so call fails with captcha needed APIError immediately
+func NewFailFastCaptchaSolver() CaptchaSolver {
+	return failFastCaptchaSolver{}
+}
+
+func (failFastCaptchaSolver) Solve(_ context.Context, _ string, _ string) (string, error) {
+	return "", ErrCaptchaNotSolved
+}
+
+// CaptchaRequest captcha waiting for operator to solve it
+type CaptchaRequest struct {
+	// SID captcha_sid
+	SID string
+
+	// Img url of captcha image
+	Img string
+
+	answer chan string
+}
+
+// Answer passes captcha_key solved by operator, only the first answer is used
+func (r *CaptchaRequest) Answer(key string) {
+	select {
+	case r.answer <- key:
+	default:
+	}
+}
+
+// ChanCaptchaSolver passes captchas to operator through channel,
+// e.g. to send captcha image to admin chat and answer with admin's reply
+type ChanCaptchaSolver struct {
+	requests chan *CaptchaRequest
+	timeout  time.Duration
+}
+
+// NewChanCaptchaSolver creates ChanCaptchaSolver waiting for answer no longer than timeout,
+// zero timeout means waiting until call context is done
+func NewChanCaptchaSolver(timeout time.Duration) *ChanCaptchaSolver {
+	return &ChanCaptchaSolver{
+		requests: make(chan *CaptchaRequest),
+		timeout:  timeout,
+	}
+}
+
+// Requests returns channel of captchas to solve
+func (s *ChanCaptchaSolver) Requests() <-chan *CaptchaRequest {
+	return s.requests
+}
+
+// Solve sends captcha to Requests channel and waits for answer
+func (s *ChanCaptchaSolver) Solve(ctx context.Context, sid string, img string) (string, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	req := &CaptchaRequest{SID: sid, Img: img, answer: make(chan string, 1)}
+	select {
+	case s.requests <- req:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	select {
+	case key := <-req.answer:
+		return key, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
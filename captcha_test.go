@@ -0,0 +1,110 @@
+package vkbot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCaptchaServer() (*httptest.Server, *int32) {
+	calls := new(int32)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(calls, 1)
+			if r.FormValue("captcha_sid") == "test_sid" && r.FormValue("captcha_key") == "solved" {
+				w.Write([]byte(`{"response": 1}`))
+				return
+			}
+			w.Write([]byte(`{"error": {"error_code": 14, "error_msg": "Captcha needed",
+				"captcha_sid": "test_sid", "captcha_img": "https://api.vk.com/captcha.php?sid=test_sid"}}`))
+		}))
+	return server, calls
+}
+
+func TestVkApiCaptchaFailFast(t *testing.T) {
+	server, calls := newCaptchaServer()
+	defer server.Close()
+
+	api := &vkAPI{URL: server.URL + "/", client: server.Client(), config: defaultVkAPIConfig()}
+	_, err := api.CallMethod("test.Tests", Params{})
+	if !IsErrorCode(err, ErrorCodeCaptchaNeeded) {
+		t.Fatal("should be captcha needed error", err)
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr); apiErr.CaptchaSID != "test_sid" || apiErr.CaptchaImg == "" {
+		t.Errorf("captcha fields should be filled: %+v", apiErr)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("should be 1 call, got %d", n)
+	}
+}
+
+func TestVkApiCaptchaChanSolver(t *testing.T) {
+	server, calls := newCaptchaServer()
+	defer server.Close()
+
+	solver := NewChanCaptchaSolver(time.Second)
+	api := &vkAPI{URL: server.URL + "/", client: server.Client()}
+	api.SetConfig(VkAPIConfig{CaptchaSolver: solver})
+
+	go func() {
+		req := <-solver.Requests()
+		if req.SID != "test_sid" {
+			t.Error("invalid captcha sid", req.SID)
+		}
+		req.Answer("solved")
+		req.Answer("ignored")
+	}()
+
+	params := Params{}
+	resp, err := api.CallMethod("test.Tests", params)
+	if err != nil {
+		t.Fatal("should not be error", err)
+	}
+	if resp.Int("response") != 1 {
+		t.Error("invalid response", resp)
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("should be 2 calls, got %d", n)
+	}
+	if _, ok := params["captcha_key"]; ok {
+		t.Error("captcha params should be removed after call")
+	}
+}
+
+func TestVkApiCaptchaCallerParams(t *testing.T) {
+	server, calls := newCaptchaServer()
+	defer server.Close()
+
+	api := &vkAPI{URL: server.URL + "/", client: server.Client(), config: defaultVkAPIConfig()}
+	params := Params{"captcha_sid": "test_sid", "captcha_key": "solved"}
+	if _, err := api.CallMethod("test.Tests", params); err != nil {
+		t.Fatal("should not be error", err)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("should be 1 call, got %d", n)
+	}
+	if params["captcha_sid"] != "test_sid" || params["captcha_key"] != "solved" {
+		t.Error("captcha params of caller should be kept", params)
+	}
+}
+
+func TestChanCaptchaSolverTimeout(t *testing.T) {
+	solver := NewChanCaptchaSolver(10 * time.Millisecond)
+	go func() {
+		<-solver.Requests()
+	}()
+	if _, err := solver.Solve(context.Background(), "sid", "img"); err != context.DeadlineExceeded {
+		t.Error("should be deadline exceeded", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := solver.Solve(ctx, "sid", "img"); err != context.Canceled {
+		t.Error("should be canceled without reader", err)
+	}
+}
@@ -48,6 +48,10 @@ func (err *internalError) Error() string {
 	return fmt.Sprintf("message - %s; caused by - %s", err.Message, err.Inner)
 }
 
+func (err *internalError) Unwrap() error {
+	return err.Inner
+}
+
 func (err *internalError) SetMisc(name string, val interface{}) {
 	err.Misc[name] = val
 }
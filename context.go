@@ -0,0 +1,158 @@
+package vkbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AndrewShukhtin/vkbot/event"
+	"sync/atomic"
+	"time"
+)
+
+// Context of event handling, it is cancelled when handler timeout is exceeded or bot is stopped
+type Context struct {
+	context.Context
+	event.Event
+
+	bot    *VkBot
+	api    VkAPI
+	match  routeMatch
+	target *replyTarget
+
+	// answered is set when callback button click is answered
+	answered bool
+}
+
+// NewContext creates Context of event e with api used by reply helpers, e.g. to call handlers in tests
+func NewContext(ctx context.Context, api VkAPI, e event.Event) *Context {
+	return &Context{Context: ctx, Event: e, api: api}
+}
+
+// Args returns text after command of event routed by Router.Command
+func (c *Context) Args() string {
+	return c.match.args
+}
+
+// Groups returns submatches of event routed by Router.Regexp, the first element is whole match
+func (c *Context) Groups() []string {
+	return c.match.groups
+}
+
+// NamedGroups returns named submatches of event routed by Router.Regexp
+func (c *Context) NamedGroups() map[string]string {
+	return c.match.named
+}
+
+// ErrorHook receives error returned by event handler
+type ErrorHook func(c *Context, err error)
+
+// HandlerTimeoutError reported to error hook when handler overran its timeout,
+// it is reported at the deadline if handler is still running
+type HandlerTimeoutError struct {
+	EventType string
+	EventID   string
+	Timeout   time.Duration
+	Elapsed   time.Duration
+
+	// Err error returned by handler, nil if handler was still running at the deadline
+	Err error
+}
+
+func (e *HandlerTimeoutError) Error() string {
+	msg := fmt.Sprintf("handler of '%s' event %s overran timeout %v, took %v",
+		e.EventType, e.EventID, e.Timeout, e.Elapsed)
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *HandlerTimeoutError) Unwrap() error {
+	if e.Err != nil {
+		return e.Err
+	}
+	return context.DeadlineExceeded
+}
+
+// handlerTimeout returns timeout of handler of eventType, zero if not limited
+func (cfg BotConfig) handlerTimeout(eventType string) time.Duration {
+	if t, ok := cfg.HandlerTimeouts[eventType]; ok {
+		return t
+	}
+	return cfg.HandlerTimeout
+}
+
+// newEventContext creates Context of event with bot base context and handler timeout
+func (bot *VkBot) newEventContext(e event.Event) (*Context, context.CancelFunc, time.Duration) {
+	base := bot.baseContext()
+	timeout := bot.config.handlerTimeout(e.Type())
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(base, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(base)
+	}
+	return &Context{Context: ctx, Event: e, bot: bot, api: bot.vkAPI}, cancel, timeout
+}
+
+// baseContext returns context cancelled by Stop
+func (bot *VkBot) baseContext() context.Context {
+	if bot.ctx == nil {
+		return context.Background()
+	}
+	return bot.ctx
+}
+
+// checkTimeout wraps handler result into HandlerTimeoutError if handler overran timeout
+func checkTimeout(c *Context, err error, timeout time.Duration, started time.Time) error {
+	if timeout <= 0 || !errors.Is(c.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return &HandlerTimeoutError{
+		EventType: c.Type(),
+		EventID:   c.EventID(),
+		Timeout:   timeout,
+		Elapsed:   time.Since(started),
+		Err:       err,
+	}
+}
+
+const (
+	watchRunning int32 = iota
+	watchReturned
+	watchFired
+)
+
+// watchdog reports handler which is still running at its deadline,
+// so handler ignoring context does not occupy worker silently
+type watchdog struct {
+	state int32
+	timer *time.Timer
+}
+
+func (bot *VkBot) watch(c *Context, timeout time.Duration, started time.Time) *watchdog {
+	w := &watchdog{}
+	if timeout <= 0 {
+		return w
+	}
+	w.timer = time.AfterFunc(timeout, func() {
+		if atomic.CompareAndSwapInt32(&w.state, watchRunning, watchFired) {
+			bot.reportError(c, &HandlerTimeoutError{
+				EventType: c.Type(),
+				EventID:   c.EventID(),
+				Timeout:   timeout,
+				Elapsed:   time.Since(started),
+			})
+		}
+	})
+	return w
+}
+
+// stop stops watchdog after handler returned, it returns false if overrun is already reported
+func (w *watchdog) stop() bool {
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	return atomic.CompareAndSwapInt32(&w.state, watchRunning, watchReturned)
+}
@@ -0,0 +1,150 @@
+package vkbot
+
+import (
+	"context"
+	"errors"
+	"github.com/AndrewShukhtin/vkbot/event"
+	"testing"
+	"time"
+)
+
+func TestBotConfig_HandlerTimeout(t *testing.T) {
+	cfg := BotConfig{
+		HandlerTimeout:  time.Second,
+		HandlerTimeouts: map[string]time.Duration{event.MessageEventType: time.Minute, event.WallPostNewType: 0},
+	}
+	if cfg.handlerTimeout(event.MessageNewType) != time.Second {
+		t.Error("should be default timeout")
+	}
+	if cfg.handlerTimeout(event.MessageEventType) != time.Minute {
+		t.Error("should be timeout of event type")
+	}
+	if cfg.handlerTimeout(event.WallPostNewType) != 0 {
+		t.Error("should be disabled timeout of event type")
+	}
+}
+
+func TestVkBot_HandlerDeadline(t *testing.T) {
+	bot := &VkBot{
+		handlers: map[string]HandleFunc{},
+		config: BotConfig{
+			HandlerTimeout:  time.Hour,
+			HandlerTimeouts: map[string]time.Duration{event.MessageNewType: time.Minute},
+		},
+	}
+	var left time.Duration
+	var hasDeadline bool
+	bot.EventHandler(event.MessageNewType, func(c *Context) error {
+		var deadline time.Time
+		deadline, hasDeadline = c.Deadline()
+		left = time.Until(deadline)
+		return nil
+	})
+	bot.buildChains()
+	bot.handleEvent(newMessageNewEvent(t, "go", ""))
+	if !hasDeadline || left > time.Minute || left < 59*time.Second {
+		t.Error("should be deadline of event type timeout", left)
+	}
+}
+
+func TestVkBot_HandlerTimeoutReportedAtDeadline(t *testing.T) {
+	bot := &VkBot{
+		handlers: map[string]HandleFunc{},
+		config:   BotConfig{HandlerTimeout: 10 * time.Millisecond},
+	}
+	release := make(chan struct{})
+	bot.EventHandler(event.MessageNewType, func(_ *Context) error {
+		// handler ignores context
+		<-release
+		return nil
+	})
+	reported := make(chan error, 2)
+	bot.OnError(func(_ *Context, err error) {
+		reported <- err
+	})
+	bot.buildChains()
+	handled := make(chan struct{})
+	go func() {
+		defer close(handled)
+		bot.handleEvent(newMessageNewEvent(t, "go", ""))
+	}()
+
+	var err error
+	select {
+	case err = <-reported:
+	case <-time.After(time.Second):
+		t.Fatal("should be reported timeout of running handler")
+	}
+	var timeoutErr *HandlerTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatal("should be reported timeout error", err)
+	}
+	if timeoutErr.EventType != event.MessageNewType || timeoutErr.Timeout != 10*time.Millisecond ||
+		timeoutErr.Elapsed < timeoutErr.Timeout || timeoutErr.Err != nil {
+		t.Error("should be filled timeout error", timeoutErr)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("should be deadline exceeded", err)
+	}
+
+	close(release)
+	<-handled
+	if len(reported) != 0 {
+		t.Error("should be reported once", <-reported)
+	}
+}
+
+func TestVkBot_HandlerTimeoutReportedOnce(t *testing.T) {
+	bot := &VkBot{
+		handlers: map[string]HandleFunc{},
+		config:   BotConfig{HandlerTimeout: 10 * time.Millisecond},
+	}
+	bot.EventHandler(event.MessageNewType, func(c *Context) error {
+		<-c.Done()
+		return c.Err()
+	})
+	reported := make(chan error, 2)
+	bot.OnError(func(_ *Context, err error) {
+		reported <- err
+	})
+	bot.buildChains()
+	bot.handleEvent(newMessageNewEvent(t, "go", ""))
+	// watchdog may report after handler returned
+	time.Sleep(20 * time.Millisecond)
+	if len(reported) != 1 {
+		t.Fatal("should be reported once", len(reported))
+	}
+	var timeoutErr *HandlerTimeoutError
+	if err := <-reported; !errors.As(err, &timeoutErr) {
+		t.Error("should be reported timeout error", err)
+	}
+}
+
+func TestVkBot_HandlerCancelledOnStop(t *testing.T) {
+	bot := &VkBot{handlers: map[string]HandleFunc{}}
+	bot.ctx, bot.cancel = context.WithCancel(context.Background())
+	started := make(chan struct{})
+	bot.EventHandler(event.MessageNewType, func(c *Context) error {
+		close(started)
+		<-c.Done()
+		return c.Err()
+	})
+	var reported error
+	done := make(chan struct{})
+	bot.OnError(func(c *Context, err error) {
+		reported = err
+		close(done)
+	})
+	bot.buildChains()
+	go bot.handleEvent(newMessageNewEvent(t, "go", ""))
+	<-started
+	bot.cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("should be cancelled handler")
+	}
+	if !errors.Is(reported, context.Canceled) {
+		t.Error("should be cancelled context", reported)
+	}
+}
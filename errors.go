@@ -0,0 +1,141 @@
+package vkbot
+
+import (
+	"errors"
+	"fmt"
+	"github.com/karlseguin/typed"
+)
+
+// Well-known error codes returned by vk api
+const (
+	ErrorCodeUnknown                 = 1
+	ErrorCodeAppDisabled             = 2
+	ErrorCodeUnknownMethod           = 3
+	ErrorCodeIncorrectSignature      = 4
+	ErrorCodeAuthFailed              = 5
+	ErrorCodeTooManyRequests         = 6
+	ErrorCodePermissionDenied        = 7
+	ErrorCodeInvalidRequest          = 8
+	ErrorCodeFloodControl            = 9
+	ErrorCodeInternalServerError     = 10
+	ErrorCodeTestMode                = 11
+	ErrorCodeCaptchaNeeded           = 14
+	ErrorCodeAccessDenied            = 15
+	ErrorCodeHTTPSRequired           = 16
+	ErrorCodeValidationRequired      = 17
+	ErrorCodeUserDeleted             = 18
+	ErrorCodeStandaloneOnly          = 20
+	ErrorCodeMethodDisabled          = 23
+	ErrorCodeConfirmationRequired    = 24
+	ErrorCodeGroupAuthFailed         = 27
+	ErrorCodeAppAuthFailed           = 28
+	ErrorCodeRateLimitReached        = 29
+	ErrorCodePrivateProfile          = 30
+	ErrorCodeParamInvalid            = 100
+	ErrorCodeInvalidUserID           = 113
+	ErrorCodeInvalidTimestamp        = 150
+	ErrorCodeGroupAccessDenied       = 203
+	ErrorCodeMessagesUserBlocked     = 900
+	ErrorCodeMessagesDenySend        = 901
+	ErrorCodeMessagesPrivacy         = 902
+	ErrorCodeMessagesKeyboardInvalid = 911
+	ErrorCodeMessagesChatBotFeature  = 912
+	ErrorCodeMessagesTooManyFwd      = 913
+	ErrorCodeMessagesTooLong         = 914
+	ErrorCodeMessagesChatAccess      = 917
+	ErrorCodeMessagesCantForward     = 921
+	ErrorCodeMessagesChatNotAdmin    = 925
+)
+
+// ErrThrottled returned when call would wait for rate limiter longer than allowed
+var ErrThrottled = errors.New("vk api call throttled")
+
+// ErrBatchFull returned when adding call to Batch with MaxBatchSize calls
+var ErrBatchFull = errors.New("batch is full")
+
+// ErrCaptchaNotSolved returned by CaptchaSolver which did not solve captcha
+var ErrCaptchaNotSolved = errors.New("captcha not solved")
+
+// ErrUpdatesLoopStopped returned by VkBot.Run when long poll updates loop stopped without fatal error
+var ErrUpdatesLoopStopped = errors.New("long poll updates loop stopped")
+
+// ErrNoConversation returned by Context reply helpers called for event without conversation
+var ErrNoConversation = errors.New("event has no conversation")
+
+// ErrNoVkAPI returned by Context reply helpers when Context is created without VkAPI
+var ErrNoVkAPI = errors.New("context has no vk api")
+
+// RequestParam key-value pair of request echoed by vk api in error response
+type RequestParam struct {
+	Key   string
+	Value string
+}
+
+// APIError error returned by vk api in 'error' field of response
+//
+// Use errors.As to get the details or errors.Is to compare codes:
+//
+//	errors.Is(err, &APIError{Code: ErrorCodeAccessDenied})
+type APIError struct {
+	// Method name of called method
+	Method string
+
+	// Code vk api error_code
+	Code int
+
+	// Message vk api error_msg
+	Message string
+
+	// RequestParams vk api request_params
+	RequestParams []RequestParam
+
+	// CaptchaSID vk api captcha_sid of captcha needed error
+	CaptchaSID string
+
+	// CaptchaImg vk api captcha_img of captcha needed error
+	CaptchaImg string
+}
+
+func (err *APIError) Error() string {
+	return fmt.Sprintf("vk api error %d calling %s: %s", err.Code, err.Method, err.Message)
+}
+
+// Is reports whether target is *APIError with the same code
+func (err *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	if !ok {
+		return false
+	}
+	return t.Code == err.Code
+}
+
+// IsErrorCode reports whether any error in err's chain is *APIError with one of codes
+func IsErrorCode(err error, codes ...int) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	for _, c := range codes {
+		if apiErr.Code == c {
+			return true
+		}
+	}
+	return false
+}
+
+func newAPIError(methodName string, data typed.Typed) *APIError {
+	err := &APIError{
+		Method:     methodName,
+		Code:       data.Int("error_code"),
+		Message:    data.String("error_msg"),
+		CaptchaSID: data.String("captcha_sid"),
+		CaptchaImg: data.String("captcha_img"),
+	}
+	for _, p := range data.Objects("request_params") {
+		err.RequestParams = append(err.RequestParams, RequestParam{
+			Key:   p.String("key"),
+			Value: p.String("value"),
+		})
+	}
+	return err
+}
@@ -9,4 +9,62 @@ const (
 	MessageDenyType        = "message_deny"
 	MessageTypingStateType = "message_typing_state"
 	MessageEventType       = "message_event"
+	MessageReadType        = "message_read"
+
+	PhotoNewType            = "photo_new"
+	PhotoCommentNewType     = "photo_comment_new"
+	PhotoCommentEditType    = "photo_comment_edit"
+	PhotoCommentRestoreType = "photo_comment_restore"
+	PhotoCommentDeleteType  = "photo_comment_delete"
+
+	AudioNewType = "audio_new"
+
+	VideoNewType            = "video_new"
+	VideoCommentNewType     = "video_comment_new"
+	VideoCommentEditType    = "video_comment_edit"
+	VideoCommentRestoreType = "video_comment_restore"
+	VideoCommentDeleteType  = "video_comment_delete"
+
+	WallPostNewType      = "wall_post_new"
+	WallRepostType       = "wall_repost"
+	WallReplyNewType     = "wall_reply_new"
+	WallReplyEditType    = "wall_reply_edit"
+	WallReplyRestoreType = "wall_reply_restore"
+	WallReplyDeleteType  = "wall_reply_delete"
+
+	LikeAddType    = "like_add"
+	LikeRemoveType = "like_remove"
+
+	BoardPostNewType     = "board_post_new"
+	BoardPostEditType    = "board_post_edit"
+	BoardPostRestoreType = "board_post_restore"
+	BoardPostDeleteType  = "board_post_delete"
+
+	MarketCommentNewType     = "market_comment_new"
+	MarketCommentEditType    = "market_comment_edit"
+	MarketCommentRestoreType = "market_comment_restore"
+	MarketCommentDeleteType  = "market_comment_delete"
+	MarketOrderNewType       = "market_order_new"
+	MarketOrderEditType      = "market_order_edit"
+
+	GroupLeaveType          = "group_leave"
+	GroupJoinType           = "group_join"
+	UserBlockType           = "user_block"
+	UserUnblockType         = "user_unblock"
+	PollVoteNewType         = "poll_vote_new"
+	GroupOfficersEditType   = "group_officers_edit"
+	GroupChangeSettingsType = "group_change_settings"
+	GroupChangePhotoType    = "group_change_photo"
+
+	VkpayTransactionType = "vkpay_transaction"
+	AppPayloadType       = "app_payload"
+	LeadFormsNewType     = "lead_forms_new"
+
+	DonutSubscriptionCreateType       = "donut_subscription_create"
+	DonutSubscriptionProlongedType    = "donut_subscription_prolonged"
+	DonutSubscriptionExpiredType      = "donut_subscription_expired"
+	DonutSubscriptionCancelledType    = "donut_subscription_cancelled"
+	DonutSubscriptionPriceChangedType = "donut_subscription_price_changed"
+	DonutMoneyWithdrawType            = "donut_money_withdraw"
+	DonutMoneyWithdrawErrorType       = "donut_money_withdraw_error"
 )
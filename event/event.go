@@ -2,6 +2,7 @@ package event
 
 import (
 	"fmt"
+	"github.com/AndrewShukhtin/vkbot/attachment"
 	"github.com/karlseguin/typed"
 )
 
@@ -22,6 +23,93 @@ type (
 
 		// EventID returns event event_id
 		EventID() string
+
+		// Typed accessors decode event object,
+		// they return error wrapping ErrTypeMismatch if event has another type
+
+		// AsMessageNew returns object of message_new event
+		AsMessageNew() (*MessageNew, error)
+		// AsMessage returns object of message_reply and message_edit events
+		AsMessage() (*Message, error)
+		// AsMessageAllow returns object of message_allow event
+		AsMessageAllow() (*MessageAllow, error)
+		// AsMessageDeny returns object of message_deny event
+		AsMessageDeny() (*MessageDeny, error)
+		// AsMessageTypingState returns object of message_typing_state event
+		AsMessageTypingState() (*MessageTypingState, error)
+		// AsMessageEvent returns object of message_event event
+		AsMessageEvent() (*MessageEvent, error)
+		// AsMessageRead returns object of message_read event
+		AsMessageRead() (*MessageRead, error)
+
+		// AsPhotoNew returns object of photo_new event
+		AsPhotoNew() (*attachment.Photo, error)
+		// AsPhotoComment returns object of photo_comment_new, photo_comment_edit and photo_comment_restore events
+		AsPhotoComment() (*PhotoComment, error)
+		// AsPhotoCommentDelete returns object of photo_comment_delete event
+		AsPhotoCommentDelete() (*PhotoCommentDelete, error)
+		// AsAudioNew returns object of audio_new event
+		AsAudioNew() (*attachment.Audio, error)
+		// AsVideoNew returns object of video_new event
+		AsVideoNew() (*attachment.Video, error)
+		// AsVideoComment returns object of video_comment_new, video_comment_edit and video_comment_restore events
+		AsVideoComment() (*VideoComment, error)
+		// AsVideoCommentDelete returns object of video_comment_delete event
+		AsVideoCommentDelete() (*VideoCommentDelete, error)
+
+		// AsWallPost returns object of wall_post_new and wall_repost events
+		AsWallPost() (*WallPost, error)
+		// AsWallReply returns object of wall_reply_new, wall_reply_edit and wall_reply_restore events
+		AsWallReply() (*WallReply, error)
+		// AsWallReplyDelete returns object of wall_reply_delete event
+		AsWallReplyDelete() (*WallReplyDelete, error)
+		// AsLike returns object of like_add and like_remove events
+		AsLike() (*Like, error)
+		// AsBoardPost returns object of board_post_new, board_post_edit and board_post_restore events
+		AsBoardPost() (*BoardPost, error)
+		// AsBoardPostDelete returns object of board_post_delete event
+		AsBoardPostDelete() (*BoardPostDelete, error)
+		// AsMarketComment returns object of market_comment_new, market_comment_edit and market_comment_restore events
+		AsMarketComment() (*MarketComment, error)
+		// AsMarketCommentDelete returns object of market_comment_delete event
+		AsMarketCommentDelete() (*MarketCommentDelete, error)
+		// AsMarketOrder returns object of market_order_new and market_order_edit events
+		AsMarketOrder() (*MarketOrder, error)
+
+		// AsGroupLeave returns object of group_leave event
+		AsGroupLeave() (*GroupLeave, error)
+		// AsGroupJoin returns object of group_join event
+		AsGroupJoin() (*GroupJoin, error)
+		// AsUserBlock returns object of user_block event
+		AsUserBlock() (*UserBlock, error)
+		// AsUserUnblock returns object of user_unblock event
+		AsUserUnblock() (*UserUnblock, error)
+		// AsPollVoteNew returns object of poll_vote_new event
+		AsPollVoteNew() (*PollVoteNew, error)
+		// AsGroupOfficersEdit returns object of group_officers_edit event
+		AsGroupOfficersEdit() (*GroupOfficersEdit, error)
+		// AsGroupChangeSettings returns object of group_change_settings event
+		AsGroupChangeSettings() (*GroupChangeSettings, error)
+		// AsGroupChangePhoto returns object of group_change_photo event
+		AsGroupChangePhoto() (*GroupChangePhoto, error)
+
+		// AsVkpayTransaction returns object of vkpay_transaction event
+		AsVkpayTransaction() (*VkpayTransaction, error)
+		// AsAppPayload returns object of app_payload event
+		AsAppPayload() (*AppPayload, error)
+		// AsLeadFormsNew returns object of lead_forms_new event
+		AsLeadFormsNew() (*LeadFormsNew, error)
+
+		// AsDonutSubscription returns object of donut_subscription_create and donut_subscription_prolonged events
+		AsDonutSubscription() (*DonutSubscription, error)
+		// AsDonutSubscriptionEnd returns object of donut_subscription_expired and donut_subscription_cancelled events
+		AsDonutSubscriptionEnd() (*DonutSubscriptionEnd, error)
+		// AsDonutSubscriptionPriceChanged returns object of donut_subscription_price_changed event
+		AsDonutSubscriptionPriceChanged() (*DonutSubscriptionPriceChanged, error)
+		// AsDonutMoneyWithdraw returns object of donut_money_withdraw event
+		AsDonutMoneyWithdraw() (*DonutMoneyWithdraw, error)
+		// AsDonutMoneyWithdrawError returns object of donut_money_withdraw_error event
+		AsDonutMoneyWithdrawError() (*DonutMoneyWithdrawError, error)
 	}
 
 	event struct {
@@ -68,15 +156,8 @@ func parseToEventType(update typed.Typed) (*event, error) {
 		return nil, fmt.Errorf("event invalid 'event_id' field")
 	}
 
-	switch update.String("type") {
-	case MessageNewType:
-	case MessageReplyType, MessageEditType:
-	case MessageAllowType:
-	case MessageDenyType:
-	case MessageTypingStateType:
-	case MessageEventType:
-	default:
-		return nil, fmt.Errorf("not supported event type")
+	if t := update.String("type"); !IsSupported(t) {
+		return nil, fmt.Errorf("not supported event type '%s'", t)
 	}
 	return &event{data: update}, nil
 }
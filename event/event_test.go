@@ -38,6 +38,13 @@ func TestSupportedEventsType(t *testing.T) {
 		MessageDenyType,
 		MessageTypingStateType,
 		MessageEventType,
+		MessageReadType,
+		WallPostNewType,
+		GroupJoinType,
+		MarketOrderNewType,
+		LikeAddType,
+		VkpayTransactionType,
+		DonutMoneyWithdrawErrorType,
 	}
 	for _, et := range eventTypes {
 		e := map[string]interface{}{
@@ -0,0 +1,585 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AndrewShukhtin/vkbot/attachment"
+	"github.com/karlseguin/typed"
+	"strings"
+)
+
+// ErrTypeMismatch returned by typed accessor called for event of another type
+var ErrTypeMismatch = errors.New("event type mismatch")
+
+type (
+	// ClientInfo features supported by user's client
+	ClientInfo struct {
+		ButtonActions  []string `json:"button_actions"`
+		Keyboard       bool     `json:"keyboard"`
+		InlineKeyboard bool     `json:"inline_keyboard"`
+		Carousel       bool     `json:"carousel"`
+		LangID         int      `json:"lang_id"`
+	}
+
+	// MessageAction service action of chat message
+	MessageAction struct {
+		Type     string `json:"type"`
+		MemberID int    `json:"member_id"`
+		Text     string `json:"text"`
+		Email    string `json:"email"`
+	}
+
+	// Message private or chat message
+	Message struct {
+		ID                    int             `json:"id"`
+		Date                  int             `json:"date"`
+		UpdateTime            int             `json:"update_time"`
+		PeerID                int             `json:"peer_id"`
+		FromID                int             `json:"from_id"`
+		Text                  string          `json:"text"`
+		RandomID              int             `json:"random_id"`
+		ConversationMessageID int             `json:"conversation_message_id"`
+		Ref                   string          `json:"ref"`
+		RefSource             string          `json:"ref_source"`
+		Attachments           []typed.Typed   `json:"attachments"`
+		Important             bool            `json:"important"`
+		Payload               string          `json:"payload"`
+		Keyboard              json.RawMessage `json:"keyboard"`
+		FwdMessages           []Message       `json:"fwd_messages"`
+		ReplyMessage          *Message        `json:"reply_message"`
+		Action                *MessageAction  `json:"action"`
+		AdminAuthorID         int             `json:"admin_author_id"`
+		IsHidden              bool            `json:"is_hidden"`
+		IsCropped             bool            `json:"is_cropped"`
+		MembersCount          int             `json:"members_count"`
+	}
+
+	// MessageNew object of message_new event
+	MessageNew struct {
+		Message    Message    `json:"message"`
+		ClientInfo ClientInfo `json:"client_info"`
+	}
+
+	// MessageAllow object of message_allow event
+	MessageAllow struct {
+		UserID int    `json:"user_id"`
+		Key    string `json:"key"`
+	}
+
+	// MessageDeny object of message_deny event
+	MessageDeny struct {
+		UserID int `json:"user_id"`
+	}
+
+	// MessageTypingState object of message_typing_state event
+	MessageTypingState struct {
+		State  string `json:"state"`
+		FromID int    `json:"from_id"`
+		ToID   int    `json:"to_id"`
+	}
+
+	// MessageEvent object of message_event event, it's sent on callback button click
+	MessageEvent struct {
+		UserID                int             `json:"user_id"`
+		PeerID                int             `json:"peer_id"`
+		EventID               string          `json:"event_id"`
+		Payload               json.RawMessage `json:"payload"`
+		ConversationMessageID int             `json:"conversation_message_id"`
+	}
+
+	// MessageRead object of message_read event
+	MessageRead struct {
+		FromID                int `json:"from_id"`
+		PeerID                int `json:"peer_id"`
+		ReadMessageID         int `json:"read_message_id"`
+		ConversationMessageID int `json:"conversation_message_id"`
+	}
+
+	// Comment comment of photo, video, wall post, board topic or market item
+	Comment struct {
+		ID             int           `json:"id"`
+		FromID         int           `json:"from_id"`
+		Date           int           `json:"date"`
+		Text           string        `json:"text"`
+		ReplyToUser    int           `json:"reply_to_user"`
+		ReplyToComment int           `json:"reply_to_comment"`
+		Attachments    []typed.Typed `json:"attachments"`
+		ParentsStack   []int         `json:"parents_stack"`
+	}
+
+	// PhotoComment object of photo_comment_new, photo_comment_edit and photo_comment_restore events
+	PhotoComment struct {
+		Comment
+		PhotoID      int `json:"photo_id"`
+		PhotoOwnerID int `json:"photo_owner_id"`
+	}
+
+	// PhotoCommentDelete object of photo_comment_delete event
+	PhotoCommentDelete struct {
+		OwnerID   int `json:"owner_id"`
+		ID        int `json:"id"`
+		UserID    int `json:"user_id"`
+		DeleterID int `json:"deleter_id"`
+		PhotoID   int `json:"photo_id"`
+	}
+
+	// VideoComment object of video_comment_new, video_comment_edit and video_comment_restore events
+	VideoComment struct {
+		Comment
+		VideoID      int `json:"video_id"`
+		VideoOwnerID int `json:"video_owner_id"`
+	}
+
+	// VideoCommentDelete object of video_comment_delete event
+	VideoCommentDelete struct {
+		OwnerID   int `json:"owner_id"`
+		ID        int `json:"id"`
+		UserID    int `json:"user_id"`
+		DeleterID int `json:"deleter_id"`
+		VideoID   int `json:"video_id"`
+	}
+
+	// WallPost object of wall_post_new and wall_repost events
+	WallPost struct {
+		ID           int           `json:"id"`
+		OwnerID      int           `json:"owner_id"`
+		FromID       int           `json:"from_id"`
+		CreatedBy    int           `json:"created_by"`
+		Date         int           `json:"date"`
+		Text         string        `json:"text"`
+		ReplyOwnerID int           `json:"reply_owner_id"`
+		ReplyPostID  int           `json:"reply_post_id"`
+		FriendsOnly  int           `json:"friends_only"`
+		MarkedAsAds  int           `json:"marked_as_ads"`
+		PostType     string        `json:"post_type"`
+		Attachments  []typed.Typed `json:"attachments"`
+		SignerID     int           `json:"signer_id"`
+		CopyHistory  []WallPost    `json:"copy_history"`
+		PostponedID  int           `json:"postponed_id"`
+	}
+
+	// WallReply object of wall_reply_new, wall_reply_edit and wall_reply_restore events
+	WallReply struct {
+		Comment
+		PostID      int `json:"post_id"`
+		PostOwnerID int `json:"post_owner_id"`
+	}
+
+	// WallReplyDelete object of wall_reply_delete event
+	WallReplyDelete struct {
+		OwnerID   int `json:"owner_id"`
+		ID        int `json:"id"`
+		DeleterID int `json:"deleter_id"`
+		PostID    int `json:"post_id"`
+	}
+
+	// Like object of like_add and like_remove events
+	Like struct {
+		LikerID       int    `json:"liker_id"`
+		ObjectType    string `json:"object_type"`
+		ObjectOwnerID int    `json:"object_owner_id"`
+		ObjectID      int    `json:"object_id"`
+		ThreadReplyID int    `json:"thread_reply_id"`
+		PostID        int    `json:"post_id"`
+	}
+
+	// BoardPost object of board_post_new, board_post_edit and board_post_restore events
+	BoardPost struct {
+		Comment
+		TopicID      int `json:"topic_id"`
+		TopicOwnerID int `json:"topic_owner_id"`
+	}
+
+	// BoardPostDelete object of board_post_delete event
+	BoardPostDelete struct {
+		TopicOwnerID int `json:"topic_owner_id"`
+		TopicID      int `json:"topic_id"`
+		ID           int `json:"id"`
+	}
+
+	// MarketComment object of market_comment_new, market_comment_edit and market_comment_restore events
+	MarketComment struct {
+		Comment
+		MarketOwnerID int `json:"market_owner_id"`
+		ItemID        int `json:"item_id"`
+	}
+
+	// MarketCommentDelete object of market_comment_delete event
+	MarketCommentDelete struct {
+		OwnerID   int `json:"owner_id"`
+		ID        int `json:"id"`
+		UserID    int `json:"user_id"`
+		DeleterID int `json:"deleter_id"`
+		ItemID    int `json:"item_id"`
+	}
+
+	// MarketOrder object of market_order_new and market_order_edit events
+	MarketOrder struct {
+		ID             int         `json:"id"`
+		GroupID        int         `json:"group_id"`
+		UserID         int         `json:"user_id"`
+		Date           int         `json:"date"`
+		Status         int         `json:"status"`
+		ItemsCount     int         `json:"items_count"`
+		TotalPrice     typed.Typed `json:"total_price"`
+		DisplayOrderID string      `json:"display_order_id"`
+		Comment        string      `json:"comment"`
+	}
+
+	// GroupLeave object of group_leave event
+	GroupLeave struct {
+		UserID int `json:"user_id"`
+		// Self 1 if user left by himself, 0 if he was removed
+		Self int `json:"self"`
+	}
+
+	// GroupJoin object of group_join event
+	GroupJoin struct {
+		UserID   int    `json:"user_id"`
+		JoinType string `json:"join_type"`
+	}
+
+	// UserBlock object of user_block event
+	UserBlock struct {
+		AdminID     int    `json:"admin_id"`
+		UserID      int    `json:"user_id"`
+		UnblockDate int    `json:"unblock_date"`
+		Reason      int    `json:"reason"`
+		Comment     string `json:"comment"`
+	}
+
+	// UserUnblock object of user_unblock event
+	UserUnblock struct {
+		AdminID   int `json:"admin_id"`
+		UserID    int `json:"user_id"`
+		ByEndDate int `json:"by_end_date"`
+	}
+
+	// PollVoteNew object of poll_vote_new event
+	PollVoteNew struct {
+		OwnerID  int `json:"owner_id"`
+		PollID   int `json:"poll_id"`
+		OptionID int `json:"option_id"`
+		UserID   int `json:"user_id"`
+	}
+
+	// GroupOfficersEdit object of group_officers_edit event
+	GroupOfficersEdit struct {
+		AdminID  int `json:"admin_id"`
+		UserID   int `json:"user_id"`
+		LevelOld int `json:"level_old"`
+		LevelNew int `json:"level_new"`
+	}
+
+	// GroupChangeSettings object of group_change_settings event
+	GroupChangeSettings struct {
+		UserID  int                    `json:"user_id"`
+		Changes map[string]typed.Typed `json:"changes"`
+	}
+
+	// GroupChangePhoto object of group_change_photo event
+	GroupChangePhoto struct {
+		UserID int              `json:"user_id"`
+		Photo  attachment.Photo `json:"photo"`
+	}
+
+	// VkpayTransaction object of vkpay_transaction event
+	VkpayTransaction struct {
+		FromID      int    `json:"from_id"`
+		Amount      int    `json:"amount"`
+		Description string `json:"description"`
+		Date        int    `json:"date"`
+	}
+
+	// AppPayload object of app_payload event
+	AppPayload struct {
+		UserID  int    `json:"user_id"`
+		AppID   int    `json:"app_id"`
+		Payload string `json:"payload"`
+		GroupID int    `json:"group_id"`
+	}
+
+	// LeadFormAnswer answer of lead form
+	LeadFormAnswer struct {
+		Key      string `json:"key"`
+		Question string `json:"question"`
+		Answer   string `json:"answer"`
+	}
+
+	// LeadFormsNew object of lead_forms_new event
+	LeadFormsNew struct {
+		LeadID   int              `json:"lead_id"`
+		GroupID  int              `json:"group_id"`
+		UserID   int              `json:"user_id"`
+		FormID   int              `json:"form_id"`
+		FormName string           `json:"form_name"`
+		AdID     int              `json:"ad_id"`
+		Answers  []LeadFormAnswer `json:"answers"`
+	}
+
+	// DonutSubscription object of donut_subscription_create and donut_subscription_prolonged events
+	DonutSubscription struct {
+		UserID           int     `json:"user_id"`
+		Amount           int     `json:"amount"`
+		AmountWithoutFee float64 `json:"amount_without_fee"`
+	}
+
+	// DonutSubscriptionEnd object of donut_subscription_expired and donut_subscription_cancelled events
+	DonutSubscriptionEnd struct {
+		UserID int `json:"user_id"`
+	}
+
+	// DonutSubscriptionPriceChanged object of donut_subscription_price_changed event
+	DonutSubscriptionPriceChanged struct {
+		UserID               int     `json:"user_id"`
+		AmountOld            int     `json:"amount_old"`
+		AmountNew            int     `json:"amount_new"`
+		AmountDiff           float64 `json:"amount_diff"`
+		AmountDiffWithoutFee float64 `json:"amount_diff_without_fee"`
+	}
+
+	// DonutMoneyWithdraw object of donut_money_withdraw event
+	DonutMoneyWithdraw struct {
+		Amount           float64 `json:"amount"`
+		AmountWithoutFee float64 `json:"amount_without_fee"`
+	}
+
+	// DonutMoneyWithdrawError object of donut_money_withdraw_error event
+	DonutMoneyWithdrawError struct {
+		Reason string `json:"reason"`
+	}
+)
+
+// ParseAttachments parses message attachments
+func (m *Message) ParseAttachments() ([]attachment.Attachment, error) {
+	return attachment.FromObjects(m.Attachments)
+}
+
+// ParseAttachments parses comment attachments
+func (c *Comment) ParseAttachments() ([]attachment.Attachment, error) {
+	return attachment.FromObjects(c.Attachments)
+}
+
+// ParseAttachments parses wall post attachments
+func (p *WallPost) ParseAttachments() ([]attachment.Attachment, error) {
+	return attachment.FromObjects(p.Attachments)
+}
+
+// decodeObject decodes event object into v if event has one of types
+func (e *event) decodeObject(v interface{}, types ...string) error {
+	t := e.Type()
+	matched := false
+	for _, et := range types {
+		if et == t {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return fmt.Errorf("%w: '%s' event is not %s", ErrTypeMismatch, t, strings.Join(types, " or "))
+	}
+	data, err := json.Marshal(e.Object())
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid '%s' event object: %w", t, err)
+	}
+	return nil
+}
+
+func (e *event) AsMessageNew() (*MessageNew, error) {
+	res := &MessageNew{}
+	return res, e.decodeObject(res, MessageNewType)
+}
+
+func (e *event) AsMessage() (*Message, error) {
+	res := &Message{}
+	return res, e.decodeObject(res, MessageReplyType, MessageEditType)
+}
+
+func (e *event) AsMessageAllow() (*MessageAllow, error) {
+	res := &MessageAllow{}
+	return res, e.decodeObject(res, MessageAllowType)
+}
+
+func (e *event) AsMessageDeny() (*MessageDeny, error) {
+	res := &MessageDeny{}
+	return res, e.decodeObject(res, MessageDenyType)
+}
+
+func (e *event) AsMessageTypingState() (*MessageTypingState, error) {
+	res := &MessageTypingState{}
+	return res, e.decodeObject(res, MessageTypingStateType)
+}
+
+func (e *event) AsMessageEvent() (*MessageEvent, error) {
+	res := &MessageEvent{}
+	return res, e.decodeObject(res, MessageEventType)
+}
+
+func (e *event) AsMessageRead() (*MessageRead, error) {
+	res := &MessageRead{}
+	return res, e.decodeObject(res, MessageReadType)
+}
+
+func (e *event) AsPhotoNew() (*attachment.Photo, error) {
+	res := &attachment.Photo{}
+	return res, e.decodeObject(res, PhotoNewType)
+}
+
+func (e *event) AsPhotoComment() (*PhotoComment, error) {
+	res := &PhotoComment{}
+	return res, e.decodeObject(res, PhotoCommentNewType, PhotoCommentEditType, PhotoCommentRestoreType)
+}
+
+func (e *event) AsPhotoCommentDelete() (*PhotoCommentDelete, error) {
+	res := &PhotoCommentDelete{}
+	return res, e.decodeObject(res, PhotoCommentDeleteType)
+}
+
+func (e *event) AsAudioNew() (*attachment.Audio, error) {
+	res := &attachment.Audio{}
+	return res, e.decodeObject(res, AudioNewType)
+}
+
+func (e *event) AsVideoNew() (*attachment.Video, error) {
+	res := &attachment.Video{}
+	return res, e.decodeObject(res, VideoNewType)
+}
+
+func (e *event) AsVideoComment() (*VideoComment, error) {
+	res := &VideoComment{}
+	return res, e.decodeObject(res, VideoCommentNewType, VideoCommentEditType, VideoCommentRestoreType)
+}
+
+func (e *event) AsVideoCommentDelete() (*VideoCommentDelete, error) {
+	res := &VideoCommentDelete{}
+	return res, e.decodeObject(res, VideoCommentDeleteType)
+}
+
+func (e *event) AsWallPost() (*WallPost, error) {
+	res := &WallPost{}
+	return res, e.decodeObject(res, WallPostNewType, WallRepostType)
+}
+
+func (e *event) AsWallReply() (*WallReply, error) {
+	res := &WallReply{}
+	return res, e.decodeObject(res, WallReplyNewType, WallReplyEditType, WallReplyRestoreType)
+}
+
+func (e *event) AsWallReplyDelete() (*WallReplyDelete, error) {
+	res := &WallReplyDelete{}
+	return res, e.decodeObject(res, WallReplyDeleteType)
+}
+
+func (e *event) AsLike() (*Like, error) {
+	res := &Like{}
+	return res, e.decodeObject(res, LikeAddType, LikeRemoveType)
+}
+
+func (e *event) AsBoardPost() (*BoardPost, error) {
+	res := &BoardPost{}
+	return res, e.decodeObject(res, BoardPostNewType, BoardPostEditType, BoardPostRestoreType)
+}
+
+func (e *event) AsBoardPostDelete() (*BoardPostDelete, error) {
+	res := &BoardPostDelete{}
+	return res, e.decodeObject(res, BoardPostDeleteType)
+}
+
+func (e *event) AsMarketComment() (*MarketComment, error) {
+	res := &MarketComment{}
+	return res, e.decodeObject(res, MarketCommentNewType, MarketCommentEditType, MarketCommentRestoreType)
+}
+
+func (e *event) AsMarketCommentDelete() (*MarketCommentDelete, error) {
+	res := &MarketCommentDelete{}
+	return res, e.decodeObject(res, MarketCommentDeleteType)
+}
+
+func (e *event) AsMarketOrder() (*MarketOrder, error) {
+	res := &MarketOrder{}
+	return res, e.decodeObject(res, MarketOrderNewType, MarketOrderEditType)
+}
+
+func (e *event) AsGroupLeave() (*GroupLeave, error) {
+	res := &GroupLeave{}
+	return res, e.decodeObject(res, GroupLeaveType)
+}
+
+func (e *event) AsGroupJoin() (*GroupJoin, error) {
+	res := &GroupJoin{}
+	return res, e.decodeObject(res, GroupJoinType)
+}
+
+func (e *event) AsUserBlock() (*UserBlock, error) {
+	res := &UserBlock{}
+	return res, e.decodeObject(res, UserBlockType)
+}
+
+func (e *event) AsUserUnblock() (*UserUnblock, error) {
+	res := &UserUnblock{}
+	return res, e.decodeObject(res, UserUnblockType)
+}
+
+func (e *event) AsPollVoteNew() (*PollVoteNew, error) {
+	res := &PollVoteNew{}
+	return res, e.decodeObject(res, PollVoteNewType)
+}
+
+func (e *event) AsGroupOfficersEdit() (*GroupOfficersEdit, error) {
+	res := &GroupOfficersEdit{}
+	return res, e.decodeObject(res, GroupOfficersEditType)
+}
+
+func (e *event) AsGroupChangeSettings() (*GroupChangeSettings, error) {
+	res := &GroupChangeSettings{}
+	return res, e.decodeObject(res, GroupChangeSettingsType)
+}
+
+func (e *event) AsGroupChangePhoto() (*GroupChangePhoto, error) {
+	res := &GroupChangePhoto{}
+	return res, e.decodeObject(res, GroupChangePhotoType)
+}
+
+func (e *event) AsVkpayTransaction() (*VkpayTransaction, error) {
+	res := &VkpayTransaction{}
+	return res, e.decodeObject(res, VkpayTransactionType)
+}
+
+func (e *event) AsAppPayload() (*AppPayload, error) {
+	res := &AppPayload{}
+	return res, e.decodeObject(res, AppPayloadType)
+}
+
+func (e *event) AsLeadFormsNew() (*LeadFormsNew, error) {
+	res := &LeadFormsNew{}
+	return res, e.decodeObject(res, LeadFormsNewType)
+}
+
+func (e *event) AsDonutSubscription() (*DonutSubscription, error) {
+	res := &DonutSubscription{}
+	return res, e.decodeObject(res, DonutSubscriptionCreateType, DonutSubscriptionProlongedType)
+}
+
+func (e *event) AsDonutSubscriptionEnd() (*DonutSubscriptionEnd, error) {
+	res := &DonutSubscriptionEnd{}
+	return res, e.decodeObject(res, DonutSubscriptionExpiredType, DonutSubscriptionCancelledType)
+}
+
+func (e *event) AsDonutSubscriptionPriceChanged() (*DonutSubscriptionPriceChanged, error) {
+	res := &DonutSubscriptionPriceChanged{}
+	return res, e.decodeObject(res, DonutSubscriptionPriceChangedType)
+}
+
+func (e *event) AsDonutMoneyWithdraw() (*DonutMoneyWithdraw, error) {
+	res := &DonutMoneyWithdraw{}
+	return res, e.decodeObject(res, DonutMoneyWithdrawType)
+}
+
+func (e *event) AsDonutMoneyWithdrawError() (*DonutMoneyWithdrawError, error) {
+	res := &DonutMoneyWithdrawError{}
+	return res, e.decodeObject(res, DonutMoneyWithdrawErrorType)
+}
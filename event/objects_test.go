@@ -0,0 +1,163 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/karlseguin/typed"
+	"testing"
+)
+
+func newTestEvent(t *testing.T, eventType string, object string) *event {
+	obj, err := typed.JsonString(object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &event{data: typed.Typed{
+		"type":     eventType,
+		"object":   map[string]interface{}(obj),
+		"group_id": 1,
+		"event_id": "test_event_id",
+	}}
+}
+
+func TestEvent_AsMessageNew(t *testing.T) {
+	e := newTestEvent(t, MessageNewType, `{
+		"message": {
+			"id": 10, "peer_id": 2000000001, "from_id": 42, "text": "hi",
+			"payload": "{\"cmd\":\"start\"}",
+			"attachments": [{"type": "photo", "photo": {"id": 1, "owner_id": 2}}],
+			"reply_message": {"id": 9, "text": "prev"}
+		},
+		"client_info": {"button_actions": ["text", "callback"], "keyboard": true, "lang_id": 0}
+	}`)
+	m, err := e.AsMessageNew()
+	if err != nil {
+		t.Fatal("should not be error", err)
+	}
+	if m.Message.ID != 10 || m.Message.PeerID != 2000000001 || m.Message.FromID != 42 || m.Message.Text != "hi" {
+		t.Error("should be decoded message fields", m.Message)
+	}
+	if m.Message.Payload != `{"cmd":"start"}` {
+		t.Error("should be decoded payload", m.Message.Payload)
+	}
+	if m.Message.ReplyMessage == nil || m.Message.ReplyMessage.ID != 9 {
+		t.Error("should be decoded reply message")
+	}
+	if !m.ClientInfo.Keyboard || len(m.ClientInfo.ButtonActions) != 2 {
+		t.Error("should be decoded client info", m.ClientInfo)
+	}
+	attachments, err := m.Message.ParseAttachments()
+	if err != nil || len(attachments) != 1 || attachments[0].ID() != "photo2_1" {
+		t.Error("should be parsed attachments", attachments, err)
+	}
+}
+
+func TestEvent_AsMessageEvent(t *testing.T) {
+	e := newTestEvent(t, MessageEventType, `{
+		"user_id": 1, "peer_id": 2, "event_id": "abc",
+		"payload": {"button": "ok"}, "conversation_message_id": 3
+	}`)
+	m, err := e.AsMessageEvent()
+	if err != nil {
+		t.Fatal("should not be error", err)
+	}
+	if m.UserID != 1 || m.PeerID != 2 || m.EventID != "abc" || m.ConversationMessageID != 3 {
+		t.Error("should be decoded message event fields", m)
+	}
+	var payload map[string]string
+	if err := json.Unmarshal(m.Payload, &payload); err != nil || payload["button"] != "ok" {
+		t.Error("should be raw payload", string(m.Payload))
+	}
+}
+
+func TestEvent_AsWallPost(t *testing.T) {
+	for _, et := range []string{WallPostNewType, WallRepostType} {
+		e := newTestEvent(t, et, `{"id": 5, "owner_id": -1, "text": "post", "copy_history": [{"id": 4}]}`)
+		p, err := e.AsWallPost()
+		if err != nil {
+			t.Fatal("should not be error", err)
+		}
+		if p.ID != 5 || p.OwnerID != -1 || p.Text != "post" || len(p.CopyHistory) != 1 {
+			t.Error("should be decoded wall post", p)
+		}
+	}
+}
+
+func TestEvent_AsComments(t *testing.T) {
+	e := newTestEvent(t, PhotoCommentNewType, `{"id": 1, "from_id": 2, "text": "nice", "photo_id": 3, "photo_owner_id": -4}`)
+	c, err := e.AsPhotoComment()
+	if err != nil {
+		t.Fatal("should not be error", err)
+	}
+	if c.ID != 1 || c.FromID != 2 || c.Text != "nice" || c.PhotoID != 3 || c.PhotoOwnerID != -4 {
+		t.Error("should be decoded photo comment", c)
+	}
+
+	e = newTestEvent(t, WallReplyEditType, `{"id": 1, "post_id": 2, "post_owner_id": -3}`)
+	r, err := e.AsWallReply()
+	if err != nil {
+		t.Fatal("should not be error", err)
+	}
+	if r.ID != 1 || r.PostID != 2 || r.PostOwnerID != -3 {
+		t.Error("should be decoded wall reply", r)
+	}
+}
+
+func TestEvent_AsGroupEvents(t *testing.T) {
+	j, err := newTestEvent(t, GroupJoinType, `{"user_id": 1, "join_type": "join"}`).AsGroupJoin()
+	if err != nil || j.UserID != 1 || j.JoinType != "join" {
+		t.Error("should be decoded group join", j, err)
+	}
+	l, err := newTestEvent(t, LikeRemoveType, `{"liker_id": 1, "object_type": "post", "object_id": 2}`).AsLike()
+	if err != nil || l.LikerID != 1 || l.ObjectType != "post" || l.ObjectID != 2 {
+		t.Error("should be decoded like", l, err)
+	}
+	p, err := newTestEvent(t, VkpayTransactionType, `{"from_id": 1, "amount": 1000, "description": "d", "date": 2}`).AsVkpayTransaction()
+	if err != nil || p.FromID != 1 || p.Amount != 1000 || p.Description != "d" || p.Date != 2 {
+		t.Error("should be decoded vkpay transaction", p, err)
+	}
+}
+
+func TestEvent_AsDonutEvents(t *testing.T) {
+	for _, et := range []string{DonutSubscriptionCreateType, DonutSubscriptionProlongedType} {
+		s, err := newTestEvent(t, et, `{"user_id": 1, "amount": 100, "amount_without_fee": 93.5}`).AsDonutSubscription()
+		if err != nil || s.UserID != 1 || s.Amount != 100 || s.AmountWithoutFee != 93.5 {
+			t.Error("should be decoded donut subscription", s, err)
+		}
+	}
+	for _, et := range []string{DonutSubscriptionExpiredType, DonutSubscriptionCancelledType} {
+		s, err := newTestEvent(t, et, `{"user_id": 1}`).AsDonutSubscriptionEnd()
+		if err != nil || s.UserID != 1 {
+			t.Error("should be decoded donut subscription end", s, err)
+		}
+	}
+	w, err := newTestEvent(t, DonutMoneyWithdrawErrorType, `{"reason": "no money"}`).AsDonutMoneyWithdrawError()
+	if err != nil || w.Reason != "no money" {
+		t.Error("should be decoded donut withdraw error", w, err)
+	}
+}
+
+func TestEvent_AsTypeMismatch(t *testing.T) {
+	e := newTestEvent(t, MessageReplyType, `{"id": 1}`)
+	if _, err := e.AsMessageNew(); !errors.Is(err, ErrTypeMismatch) {
+		t.Error("should be type mismatch error", err)
+	}
+	if _, err := e.AsGroupJoin(); !errors.Is(err, ErrTypeMismatch) {
+		t.Error("should be type mismatch error", err)
+	}
+	m, err := e.AsMessage()
+	if err != nil || m.ID != 1 {
+		t.Error("should be decoded message", m, err)
+	}
+}
+
+func TestEvent_AsInvalidObject(t *testing.T) {
+	e := newTestEvent(t, GroupJoinType, `{"user_id": "not a number"}`)
+	_, err := e.AsGroupJoin()
+	if err == nil {
+		t.Error("should be error while decoding invalid object")
+	}
+	if errors.Is(err, ErrTypeMismatch) {
+		t.Error("should not be type mismatch error")
+	}
+}
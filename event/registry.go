@@ -0,0 +1,71 @@
+package event
+
+import (
+	"sort"
+	"sync"
+)
+
+var registry = struct {
+	sync.RWMutex
+	types map[string]struct{}
+}{types: map[string]struct{}{}}
+
+func init() {
+	Register(
+		MessageNewType, MessageReplyType, MessageEditType, MessageAllowType, MessageDenyType,
+		MessageTypingStateType, MessageEventType, MessageReadType,
+		PhotoNewType, PhotoCommentNewType, PhotoCommentEditType, PhotoCommentRestoreType, PhotoCommentDeleteType,
+		AudioNewType,
+		VideoNewType, VideoCommentNewType, VideoCommentEditType, VideoCommentRestoreType, VideoCommentDeleteType,
+		WallPostNewType, WallRepostType, WallReplyNewType, WallReplyEditType, WallReplyRestoreType, WallReplyDeleteType,
+		LikeAddType, LikeRemoveType,
+		BoardPostNewType, BoardPostEditType, BoardPostRestoreType, BoardPostDeleteType,
+		MarketCommentNewType, MarketCommentEditType, MarketCommentRestoreType, MarketCommentDeleteType,
+		MarketOrderNewType, MarketOrderEditType,
+		GroupLeaveType, GroupJoinType, UserBlockType, UserUnblockType, PollVoteNewType,
+		GroupOfficersEditType, GroupChangeSettingsType, GroupChangePhotoType,
+		VkpayTransactionType, AppPayloadType, LeadFormsNewType,
+		DonutSubscriptionCreateType, DonutSubscriptionProlongedType, DonutSubscriptionExpiredType,
+		DonutSubscriptionCancelledType, DonutSubscriptionPriceChangedType,
+		DonutMoneyWithdrawType, DonutMoneyWithdrawErrorType,
+	)
+}
+
+// Register adds event types to supported ones,
+// so events of types added to vk api after package release are parsed too
+func Register(eventTypes ...string) {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, et := range eventTypes {
+		registry.types[et] = struct{}{}
+	}
+}
+
+// Unregister removes event types from supported ones, events of them are not parsed anymore
+func Unregister(eventTypes ...string) {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, et := range eventTypes {
+		delete(registry.types, et)
+	}
+}
+
+// IsSupported reports whether events of eventType are parsed
+func IsSupported(eventType string) bool {
+	registry.RLock()
+	defer registry.RUnlock()
+	_, ok := registry.types[eventType]
+	return ok
+}
+
+// SupportedTypes returns sorted list of supported event types
+func SupportedTypes() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	res := make([]string, 0, len(registry.types))
+	for et := range registry.types {
+		res = append(res, et)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,58 @@
+package event
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	const customType = "custom_registered_event"
+	e := map[string]interface{}{
+		"type":     customType,
+		"object":   map[string]interface{}{},
+		"group_id": 0,
+		"event_id": "test_event_id",
+	}
+	if IsSupported(customType) {
+		t.Fatal("should not be supported before registration")
+	}
+	if _, err := NewEvent(e); err == nil {
+		t.Error("should be error while parsing not registered event")
+	}
+
+	Register(customType)
+	t.Cleanup(func() { Unregister(customType) })
+	if !IsSupported(customType) {
+		t.Error("should be supported after registration")
+	}
+	if _, err := NewEvent(e); err != nil {
+		t.Error("should not be error while parsing registered event", err)
+	}
+}
+
+func TestUnregister(t *testing.T) {
+	const customType = "custom_unregistered_event"
+	Register(customType)
+	Unregister(customType)
+	if IsSupported(customType) {
+		t.Error("should not be supported after unregistration")
+	}
+}
+
+func TestSupportedTypes(t *testing.T) {
+	types := SupportedTypes()
+	if !sort.StringsAreSorted(types) {
+		t.Error("should be sorted")
+	}
+	for _, et := range []string{MessageNewType, WallReplyDeleteType, DonutSubscriptionPriceChangedType} {
+		found := false
+		for _, st := range types {
+			if st == et {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("should be supported", et)
+		}
+	}
+}
@@ -1,16 +1,13 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"github.com/AndrewShukhtin/vkbot"
 	"github.com/AndrewShukhtin/vkbot/event"
 	"github.com/AndrewShukhtin/vkbot/keyboard"
-	"github.com/karlseguin/typed"
 	"go.uber.org/zap"
 	"os"
-	"os/signal"
 	"strconv"
-	"syscall"
 	"time"
 )
 
@@ -22,7 +19,7 @@ func buildFirstKeyboard() *keyboard.Keyboard {
 	a2.SetPayload(vkbot.Params{"cmd": "button 2"})
 
 	a3 := keyboard.NewCallbackAction("second keyboard")
-	a3.SetPayload(vkbot.Params{"type": "go_to_second"})
+	a3.SetPayload(vkbot.Params{"cmd": "go_to_second"})
 	k := keyboard.NewKeyboard(false, true)
 
 	k.AddButton(keyboard.NewButton(a1, "secondary"))
@@ -40,7 +37,7 @@ func buildSecondKeyboard() *keyboard.Keyboard {
 	a2.SetPayload(vkbot.Params{"cmd": "button 4"})
 
 	a3 := keyboard.NewCallbackAction("first keyboard")
-	a3.SetPayload(vkbot.Params{"type": "go_to_first"})
+	a3.SetPayload(vkbot.Params{"cmd": "go_to_first"})
 
 	k := keyboard.NewKeyboard(false, true)
 	k.AddButton(keyboard.NewButton(a1, "secondary"))
@@ -62,118 +59,69 @@ func NewBotApp(token string, groupID int) *BotApp {
 	vkAPI := vkbot.NewVkAPI(token)
 	longPollServer := vkbot.NewGroupLongPollServer(vkAPI, groupID)
 	longPollServer.SetSettings(vkbot.Params{"message_event": 1})
-	return &BotApp{vkBot: vkbot.NewVkBot(vkAPI, longPollServer), vkAPI: vkAPI}
+	return &BotApp{
+		vkBot: vkbot.NewVkBot(vkAPI, longPollServer),
+		vkAPI: vkAPI,
+	}
 }
 
-// MessageEventHandler handler for message_event
-func (app *BotApp) MessageEventHandler(e event.Event) error {
-	me := e.Object()
-	t := typed.New(me.Object("payload"))
-
-	if t.String("type") == "go_to_second" {
-		k, _ := app.menus["second"].JSON()
-		_, err := app.vkAPI.CallMethod("messages.edit",
-			vkbot.Params{
-				"peer_id":                 me.Int("peer_id"),
-				"message":                 "second keyboard",
-				"conversation_message_id": me.Int("conversation_message_id"),
-				"keyboard":                k,
-			})
-		if err != nil {
-			return err
-		}
-	}
-	if t.String("type") == "go_to_first" {
-		k, _ := app.menus["first"].JSON()
-		_, err := app.vkAPI.CallMethod("messages.edit",
-			vkbot.Params{
-				"peer_id":                 me.Int("peer_id"),
-				"message":                 "first keyboard",
-				"conversation_message_id": me.Int("conversation_message_id"),
-				"keyboard":                k,
-			})
-		if err != nil {
+// switchMenu returns handler of callback button which switches message keyboard to menu
+func (app *BotApp) switchMenu(menu string) vkbot.HandleFunc {
+	return func(c *vkbot.Context) error {
+		if err := c.AnswerCallback(vkbot.ShowSnackbar{Text: "switched to " + menu}); err != nil {
 			return err
 		}
+		return c.Edit(vkbot.EditParams{
+			Message:  menu + " keyboard",
+			Keyboard: app.menus[menu],
+		})
 	}
-	return nil
 }
 
-// MessageNewHandler handler for message_new
-func (app *BotApp) MessageNewHandler(e event.Event) error {
-	mn := e.Object()
-	m := mn.Object("message")
-	if m.String("text") == "go" {
-		k, _ := app.menus["first"].JSON()
-		_, err := app.vkAPI.CallMethod("messages.send",
-			vkbot.Params{
-				"peer_id":                 m.Int("peer_id"),
-				"random_id":               getRandomID(),
-				"message":                 "first keyboard",
-				"conversation_message_id": m.Int("conversation_message_id"),
-				"keyboard":                k,
-			})
-		return err
-	}
-	return nil
+// GoHandler handler for "go" message
+func (app *BotApp) GoHandler(c *vkbot.Context) error {
+	_, err := c.ReplyWithKeyboard("first keyboard", app.menus["first"])
+	return err
 }
 
 // Init initializes bot app
-func (app *BotApp) Init() error {
+func (app *BotApp) Init() {
 	app.menus = make(map[string]*keyboard.Keyboard, 2)
 	app.menus["first"] = buildFirstKeyboard()
 	app.menus["second"] = buildSecondKeyboard()
 
-	app.vkBot.EventHandler(event.MessageNewType, app.MessageNewHandler)
-	app.vkBot.EventHandler(event.MessageEventType, app.MessageEventHandler)
-	return app.vkBot.Init()
-}
-
-// Start app
-func (app *BotApp) Start() {
-	app.vkBot.Start()
+	router := vkbot.NewRouter().
+		IgnoreCase(true).
+		Text("go", app.GoHandler).
+		Payload("go_to_second", app.switchMenu("second")).
+		Payload("go_to_first", app.switchMenu("first"))
+	app.vkBot.EventHandler(event.MessageNewType, router.Handle)
+	app.vkBot.EventHandler(event.MessageEventType, router.Handle)
 }
 
-// Stop app
-func (app *BotApp) Stop() {
-	app.vkBot.Stop()
+// Run runs app until ctx is done
+func (app *BotApp) Run(ctx context.Context) error {
+	app.vkBot.SetConfig(vkbot.BotConfig{
+		Workers:             16,
+		WorkerBuffer:        4,
+		Events:              16,
+		HandlerTimeout:      10 * time.Second,
+		AutoAnswerCallbacks: true,
+		ShutdownTimeout:     5 * time.Second,
+	})
+	return app.vkBot.Run(ctx)
 }
 
 func main() {
 	GroupID, _ := strconv.Atoi(os.Getenv("VK_GROUP_ID"))
 	Token := os.Getenv("VK_GROUP_TOKEN")
 	app := NewBotApp(Token, GroupID)
+	app.Init()
 
-	if err := app.Init(); err != nil {
-		vkbot.Logger.Fatal("initialization error", zap.Error(err))
+	ctx, stop := vkbot.SignalContext(context.Background())
+	defer stop()
+	if err := app.Run(ctx); err != nil {
+		vkbot.Logger.Error("bot stopped with error", zap.Error(err))
 	}
-
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	done := make(chan bool)
-
-	go func() {
-		sig := <-sigChan
-		app.Stop()
-		vkbot.Logger.Sync()
-		fmt.Println()
-		fmt.Println("Caught", sig, "signal")
-		fmt.Print("Gracefully stop")
-		for range []int{1, 2, 3} {
-			time.Sleep(800 * time.Millisecond)
-			fmt.Print(".")
-		}
-		time.Sleep(400 * time.Millisecond)
-		fmt.Println()
-		close(done)
-	}()
-
-	app.Start()
-
-	<-done
-	close(sigChan)
+	vkbot.Logger.Sync()
 }
-
-func getRandomID() int64 {
-	return time.Now().UnixNano()
-}
\ No newline at end of file
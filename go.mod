@@ -1,11 +1,19 @@
 module github.com/AndrewShukhtin/vkbot
 
-go 1.16
+go 1.18
 
 require (
 	github.com/fatih/color v1.10.0
-	github.com/karlseguin/expect v1.0.8 // indirect
 	github.com/karlseguin/typed v1.1.7
 	go.uber.org/zap v1.16.0
 	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba
 )
+
+require (
+	github.com/karlseguin/expect v1.0.8 // indirect
+	github.com/mattn/go-colorable v0.1.8 // indirect
+	github.com/mattn/go-isatty v0.0.12 // indirect
+	go.uber.org/atomic v1.6.0 // indirect
+	go.uber.org/multierr v1.5.0 // indirect
+	golang.org/x/sys v0.0.0-20200223170610-d5e6a3e2c0ae // indirect
+)
@@ -2,6 +2,7 @@ package vkbot
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/AndrewShukhtin/vkbot/event"
 	"github.com/karlseguin/typed"
@@ -9,6 +10,7 @@ import (
 	"golang.org/x/time/rate"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -50,9 +52,21 @@ type (
 		client      *http.Client
 		hookDealer  *hookDealer
 		config      LongPollConfig
+		errMtx      sync.Mutex
+		err         error
 	}
 )
 
+// fatalErrorCodes codes of errors after which long poll can not be continued
+var fatalErrorCodes = []int{
+	ErrorCodeAppDisabled,
+	ErrorCodeAuthFailed,
+	ErrorCodeAccessDenied,
+	ErrorCodeGroupAuthFailed,
+	ErrorCodeAppAuthFailed,
+	ErrorCodeGroupAccessDenied,
+}
+
 // LongPollConfig enable to configure GroupLongPollServe
 type LongPollConfig struct {
 	// Wait max time (in seconds) to await updates
@@ -63,6 +77,10 @@ type LongPollConfig struct {
 
 	// Limiter rate limiter for incoming updates
 	Limiter *rate.Limiter
+
+	// DeadLetter receives events which failed to parse, other events of update are delivered as usual,
+	// by default such events are logged with raw json
+	DeadLetter func(*EventParseError)
 }
 
 // NewGroupLongPollServer create new GroupLongPollServer with VkAPI wrapper and group id
@@ -86,7 +104,8 @@ func (s *groupLongPollServer) Settings() Params {
 
 func (s *groupLongPollServer) SetSettings(settings Params) {
 	for k, v := range settings {
-		if _, ok := s.settings[k]; ok {
+		// events of registered types may be enabled too
+		if _, ok := s.settings[k]; ok || event.IsSupported(k) {
 			s.settings[k] = v
 		}
 	}
@@ -106,6 +125,10 @@ func (s *groupLongPollServer) SetConfig(config LongPollConfig) {
 		config.UpdateBufferSize = 10
 	}
 	s.config.UpdateBufferSize = config.UpdateBufferSize
+	if config.DeadLetter == nil {
+		config.DeadLetter = defaultDeadLetter
+	}
+	s.config.DeadLetter = config.DeadLetter
 }
 
 func (s *groupLongPollServer) Init() error {
@@ -113,11 +136,26 @@ func (s *groupLongPollServer) Init() error {
 	if err != nil {
 		return err
 	}
-	return s.init()
+	return s.init(context.Background())
+}
+
+// Err returns fatal error which stopped updates loop, e.g. revoked token,
+// nil if loop is running or stopped by StopUpdatesLoop
+func (s *groupLongPollServer) Err() error {
+	s.errMtx.Lock()
+	defer s.errMtx.Unlock()
+	return s.err
+}
+
+func (s *groupLongPollServer) setErr(err error) {
+	s.errMtx.Lock()
+	defer s.errMtx.Unlock()
+	s.err = err
 }
 
 func (s *groupLongPollServer) StartUpdatesLoop() <-chan Update {
 	out := make(chan Update, s.config.UpdateBufferSize)
+	s.setErr(nil)
 	s.eventCtx, s.eventCancel = context.WithCancel(s.eventCtx)
 
 	o := newOverHeater(time.Millisecond*50, 3)
@@ -143,12 +181,26 @@ func (s *groupLongPollServer) StartUpdatesLoop() <-chan Update {
 				if !ok {
 					return
 				}
+				if resp.Error != nil && IsErrorCode(resp.Error, fatalErrorCodes...) {
+					// At fatal error
+					s.setErr(resp.Error)
+					logInternalErrorOr("long poll stopped by fatal error", resp.Error)
+					return
+				}
 				if resp.Error != nil {
 					// At error response
 					s.hookDealer.AtResponseError(o, resp.Error)
 					continue
 				}
 				us, err := NewUpdate(resp.UnpackedResponse)
+				if parseErr, ok := err.(*UpdateParseError); ok && s.config.DeadLetter != nil {
+					// At malformed events, valid ones are delivered,
+					// malformed ones are reported to dead letter only, even if all events of update are malformed
+					for _, e := range parseErr.Events {
+						s.config.DeadLetter(e)
+					}
+					err = nil
+				}
 				if err != nil {
 					// At NewUpdate error
 					s.hookDealer.AtNewUpdateError(o, err)
@@ -177,9 +229,40 @@ type (
 		ts     string
 		events []event.Event
 	}
+
+	// EventParseError event of update which failed to parse
+	EventParseError struct {
+		// Raw json of event
+		Raw json.RawMessage
+		Err error
+	}
+
+	// UpdateParseError returned by NewUpdate with update containing only valid events
+	UpdateParseError struct {
+		Ts     string
+		Total  int
+		Events []*EventParseError
+	}
 )
 
-// NewUpdate parse new update from data
+func (e *EventParseError) Error() string {
+	return fmt.Sprintf("%s; raw event - %s", e.Err, e.Raw)
+}
+
+func (e *EventParseError) Unwrap() error {
+	return e.Err
+}
+
+func (e *UpdateParseError) Error() string {
+	msgs := make([]string, 0, len(e.Events))
+	for _, pe := range e.Events {
+		msgs = append(msgs, pe.Err.Error())
+	}
+	return fmt.Sprintf("%d of %d events failed to parse: %s", len(e.Events), e.Total, strings.Join(msgs, "; "))
+}
+
+// NewUpdate parse new update from data,
+// if some events are malformed it returns update with valid events and *UpdateParseError
 func NewUpdate(data typed.Typed) (Update, error) {
 	return parseToUpdateType(data)
 }
@@ -203,18 +286,20 @@ func parseToUpdateType(resp typed.Typed) (Update, error) {
 	if len(us) == 0 {
 		return nil, fmt.Errorf("updates field zero length")
 	}
-	var err error
 	res := &update{}
 	res.ts = resp.String("ts")
+	var parseErrs []*EventParseError
 	for _, u := range us {
-		e, parseErr := event.NewEvent(u)
-		if parseErr != nil {
-			err = parseErr
+		e, err := event.NewEvent(u)
+		if err != nil {
+			raw, _ := json.Marshal(u)
+			parseErrs = append(parseErrs, &EventParseError{Raw: raw, Err: err})
+			continue
 		}
 		res.events = append(res.events, e)
 	}
-	if err != nil {
-		return &update{}, err
+	if len(parseErrs) > 0 {
+		return res, &UpdateParseError{Ts: res.ts, Total: len(us), Events: parseErrs}
 	}
 	return res, nil
 }
@@ -226,8 +311,8 @@ func (s *groupLongPollServer) StopUpdatesLoop() {
 	s.eventCancel()
 }
 
-func (s *groupLongPollServer) init() error {
-	resp, err := s.VkAPI.CallMethod("groups.getLongPollServer", Params{"group_id": s.GroupID})
+func (s *groupLongPollServer) init(ctx context.Context) error {
+	resp, err := s.VkAPI.CallMethodContext(ctx, "groups.getLongPollServer", Params{"group_id": s.GroupID})
 	if err != nil {
 		return err
 	}
@@ -245,6 +330,18 @@ func (s *groupLongPollServer) init() error {
 	return nil
 }
 
+// longPollHistoryOutdated value of 'failed' field of long poll response with new ts to continue from,
+// other values (2 - key expired, 3 - information lost) require new key from groups.getLongPollServer
+const longPollHistoryOutdated = 1
+
+// replyTs returns ts of long poll response, it may be number in 'failed' response
+func replyTs(reply typed.Typed) string {
+	if ts, ok := reply.StringIf("ts"); ok {
+		return ts
+	}
+	return strconv.Itoa(reply.Int("ts"))
+}
+
 type unmarshalledResponseAndErr struct {
 	UnpackedResponse typed.Typed
 	Error            error
@@ -271,7 +368,15 @@ func (s *groupLongPollServer) getUpdate() chan unmarshalledResponseAndErr {
 				"act":  "a_check",
 				"wait": s.config.Wait,
 			}
-			reqBody := strings.NewReader(params.URLValues().Encode())
+			values, err := params.Values()
+			if err != nil {
+				out <- unmarshalledResponseAndErr{
+					UnpackedResponse: nil,
+					Error:            newInternalError(err, "invalid params of long poll request"),
+				}
+				return
+			}
+			reqBody := strings.NewReader(values.Encode())
 			httpReq, err := http.NewRequestWithContext(s.eventCtx, http.MethodPost, s.Server, reqBody)
 			if err != nil {
 				err := newInternalError(err, "invalid request")
@@ -315,9 +420,16 @@ func (s *groupLongPollServer) getUpdate() chan unmarshalledResponseAndErr {
 				return
 			}
 
-			if _, ok := reply["fail"]; ok {
-				// TODO: Add switch statement
-				if err = s.init(); err != nil {
+			if failed, ok := reply.IntIf("failed"); ok {
+				if failed == longPollHistoryOutdated {
+					// events history is outdated or partially lost, polling continues from new ts
+					s.mtx.Lock()
+					s.Ts = replyTs(reply)
+					s.mtx.Unlock()
+					continue
+				}
+				// key expired or information lost, auth errors of re-initialization are fatal
+				if err = s.init(s.eventCtx); err != nil {
 					out <- unmarshalledResponseAndErr{
 						UnpackedResponse: nil,
 						Error:            newInternalError(err, "error occurred while re-initialization of long-poll server"),
@@ -413,9 +525,14 @@ func defaultLongPollConfig() LongPollConfig {
 		Wait:             25,
 		UpdateBufferSize: 10,
 		Limiter:          defaultRateLimiter(),
+		DeadLetter:       defaultDeadLetter,
 	}
 }
 
+func defaultDeadLetter(e *EventParseError) {
+	Logger.Error("malformed event skipped", zap.Error(e.Err), zap.ByteString("raw", e.Raw))
+}
+
 func defaultHookDealer() *hookDealer {
 	d := &hookDealer{}
 	d.AtOverheat = func(ctx context.Context) bool {
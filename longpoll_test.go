@@ -3,10 +3,13 @@ package vkbot
 import (
 	"context"
 	"fmt"
+	"github.com/AndrewShukhtin/vkbot/event"
 	"github.com/karlseguin/typed"
 	"golang.org/x/time/rate"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"strings"
 	"sync"
@@ -29,6 +32,13 @@ func TestGroupLongPollServer_Settings(t *testing.T) {
 	if _, ok := out["test_event"]; ok {
 		t.Error("should not contain 'test_event'")
 	}
+
+	event.Register("test_registered_event")
+	t.Cleanup(func() { event.Unregister("test_registered_event") })
+	s.SetSettings(Params{"test_registered_event": 1})
+	if _, ok := s.Settings()["test_registered_event"]; !ok {
+		t.Error("should contain registered 'test_registered_event'")
+	}
 }
 
 type fakeVkAPI struct {
@@ -46,6 +56,12 @@ func (api *fakeVkAPI) CallMethod(methodName string, params Params) (typed.Typed,
 	return nil, fmt.Errorf("method not found")
 }
 
+func (api *fakeVkAPI) CallMethodContext(_ context.Context, methodName string, params Params) (typed.Typed, error) {
+	return api.CallMethod(methodName, params)
+}
+
+func (api *fakeVkAPI) SetConfig(_ VkAPIConfig) {}
+
 func TestGroupLongPollServerFailedInit(t *testing.T) {
 	tests := []map[string]typed.Typed{
 		{},
@@ -95,7 +111,7 @@ func TestGroupLongPollServer_getUpdate(t *testing.T) {
 			}
 			if strings.Contains(r.URL.Path, "failed") {
 				w.WriteHeader(http.StatusOK)
-				w.Write([]byte(`{"fail": 1}`))
+				w.Write([]byte(`{"failed": 2}`))
 			}
 			if strings.Contains(r.URL.Path, "fine") {
 				w.WriteHeader(http.StatusOK)
@@ -134,7 +150,7 @@ func TestGroupLongPollServer_getUpdate(t *testing.T) {
 			URL:     servers["simple"].URL + "/failed",
 		},
 		{
-			Name: "groupLongPollServer returned failed, reinitialization go well, but server always return 'fail'",
+			Name: "groupLongPollServer returned failed, reinitialization go well, but server always return 'failed'",
 			VkAPI: newFakeVkAPI(map[string]typed.Typed{"groups.getLongPollServer": {
 				"ts":     "test_ts",
 				"key":    "test_key",
@@ -182,6 +198,41 @@ func TestGroupLongPollServer_getUpdate(t *testing.T) {
 	}
 }
 
+func TestGroupLongPollServer_getUpdateHistoryOutdated(t *testing.T) {
+	var tsParams []string
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			values, _ := url.ParseQuery(string(body))
+			tsParams = append(tsParams, values.Get("ts"))
+			if len(tsParams) == 1 {
+				w.Write([]byte(`{"failed": 1, "ts": 30}`))
+				return
+			}
+			w.Write([]byte(`{"ts": "31", "updates": []}`))
+		}))
+	defer server.Close()
+
+	s := &groupLongPollServer{
+		VkAPI:    &authFailedVkAPI{},
+		Server:   server.URL,
+		Ts:       "10",
+		client:   server.Client(),
+		mtx:      &sync.Mutex{},
+		eventCtx: context.Background(),
+	}
+	respAndErr := <-s.getUpdate()
+	if respAndErr.Error != nil {
+		t.Fatal("should not be re-initialized", respAndErr.Error)
+	}
+	if len(tsParams) != 2 || tsParams[0] != "10" || tsParams[1] != "30" {
+		t.Error("should be polled from new ts", tsParams)
+	}
+	if s.Ts != "31" {
+		t.Error("should be ts of response", s.Ts)
+	}
+}
+
 func TestGroupLongPollServer_AtOverheat(t *testing.T) {
 	server := httptest.NewServer(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -483,3 +534,140 @@ LOOP:
 		}
 	}
 }
+
+func TestNewUpdate_MalformedEvents(t *testing.T) {
+	u, err := NewUpdate(typed.Typed{
+		"ts": "2",
+		"updates": []typed.Typed{
+			{"type": "message_new", "object": typed.Typed{}, "group_id": 0, "event_id": "ok"},
+			{"type": "test_event", "object": typed.Typed{}, "group_id": 0, "event_id": "bad"},
+		},
+	})
+	parseErr, ok := err.(*UpdateParseError)
+	if !ok {
+		t.Fatal("should be UpdateParseError", err)
+	}
+	if len(u.Events()) != 1 || u.Events()[0].EventID() != "ok" {
+		t.Error("should contain valid events")
+	}
+	if u.Ts() != "2" || parseErr.Ts != "2" || parseErr.Total != 2 || len(parseErr.Events) != 1 {
+		t.Error("should be error with malformed events", parseErr)
+	}
+	if !strings.Contains(string(parseErr.Events[0].Raw), `"event_id":"bad"`) {
+		t.Error("should contain raw event", string(parseErr.Events[0].Raw))
+	}
+}
+
+func TestGroupLongPollServer_DeadLetter(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"ts": 0, "updates" : [` +
+				`{"type": "test_event", "object" : {"test": 0}, "group_id": 0, "event_id": "bad"},` +
+				`{"type": "message_new", "object" : {"test": 0}, "group_id": 0, "event_id": "ok"}]}`))
+		}))
+
+	deadLetters := make(chan *EventParseError, 10)
+	d := &hookDealer{
+		AtOverheat: func(_ context.Context) bool {
+			return false
+		},
+		AtLimit: func(_ context.Context, _ *rate.Limiter) {
+
+		},
+		AtResponseError: func(_ *overHeater, _ error) {
+
+		},
+		AtNewUpdateError: func(_ *overHeater, err error) {
+			t.Error("should not be update error", err)
+		},
+	}
+
+	s := groupLongPollServer{
+		config: LongPollConfig{
+			Limiter:    rate.NewLimiter(rate.Inf, 0),
+			DeadLetter: func(e *EventParseError) { deadLetters <- e },
+		},
+		client:     server.Client(),
+		Server:     server.URL,
+		mtx:        &sync.Mutex{},
+		hookDealer: d,
+		eventCtx:   context.Background(),
+	}
+
+	updates := s.StartUpdatesLoop()
+	defer s.eventCancel()
+	select {
+	case u := <-updates:
+		if len(u.Events()) != 1 || u.Events()[0].EventID() != "ok" {
+			t.Error("should be delivered valid event")
+		}
+	case <-time.After(time.Millisecond * 100):
+		t.Fatal("timed out")
+	}
+	select {
+	case e := <-deadLetters:
+		if !strings.Contains(string(e.Raw), `"event_id":"bad"`) {
+			t.Error("should be malformed event in dead letter", string(e.Raw))
+		}
+	case <-time.After(time.Millisecond * 100):
+		t.Error("timed out")
+	}
+}
+
+func TestGroupLongPollServer_AllEventsMalformed(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"ts": 0, "updates" : [` +
+				`{"type": "test_event", "object" : {"test": 0}, "group_id": 0, "event_id": "bad"}]}`))
+		}))
+
+	deadLetters := make(chan *EventParseError, 10)
+	updateErrors := make(chan error, 10)
+	d := &hookDealer{
+		AtOverheat: func(_ context.Context) bool {
+			return false
+		},
+		AtLimit: func(_ context.Context, _ *rate.Limiter) {
+
+		},
+		AtResponseError: func(_ *overHeater, _ error) {
+
+		},
+		AtNewUpdateError: func(_ *overHeater, err error) {
+			updateErrors <- err
+		},
+	}
+
+	s := groupLongPollServer{
+		config: LongPollConfig{
+			Limiter:    rate.NewLimiter(rate.Inf, 0),
+			DeadLetter: func(e *EventParseError) { deadLetters <- e },
+		},
+		client:     server.Client(),
+		Server:     server.URL,
+		mtx:        &sync.Mutex{},
+		hookDealer: d,
+		eventCtx:   context.Background(),
+	}
+
+	updates := s.StartUpdatesLoop()
+	defer s.eventCancel()
+	select {
+	case <-deadLetters:
+	case <-time.After(time.Millisecond * 100):
+		t.Fatal("should be malformed event in dead letter")
+	}
+	select {
+	case u := <-updates:
+		if len(u.Events()) != 0 {
+			t.Error("should not be delivered malformed events")
+		}
+	case <-time.After(time.Millisecond * 100):
+		t.Fatal("timed out")
+	}
+	if len(updateErrors) != 0 {
+		t.Error("should not be reported update error for dead letters", <-updateErrors)
+	}
+}
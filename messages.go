@@ -0,0 +1,354 @@
+package vkbot
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/AndrewShukhtin/vkbot/keyboard"
+)
+
+// Messages typed client of vk api messages methods
+type Messages struct {
+	api VkAPI
+}
+
+// NewMessages creates Messages service on top of VkAPI
+func NewMessages(api VkAPI) *Messages {
+	return &Messages{api: api}
+}
+
+// Forward describes messages to forward or reply to
+type Forward struct {
+	OwnerID                int   `json:"owner_id,omitempty"`
+	PeerID                 int   `json:"peer_id"`
+	ConversationMessageIDs []int `json:"conversation_message_ids,omitempty"`
+	MessageIDs             []int `json:"message_ids,omitempty"`
+	IsReply                bool  `json:"is_reply,omitempty"`
+}
+
+// SendParams params of messages.send
+type SendParams struct {
+	// PeerID destination of message
+	PeerID int
+
+	// PeerIDs destinations of message, mutually exclusive with PeerID, Send fails if both are set
+	PeerIDs []int
+
+	UserID int
+	ChatID int
+	Domain string
+
+	// RandomID unique id of message, generated if zero
+	RandomID int64
+
+	Message     string
+	Lat         float64
+	Long        float64
+	Attachments []string
+
+	ReplyTo         int
+	ForwardMessages []int
+	Forward         *Forward
+
+	StickerID int
+	Keyboard  *keyboard.Keyboard
+
+	// Template carousel template, marshaled to json unless it is string
+	Template interface{}
+
+	// Payload message payload, marshaled to json unless it is string
+	Payload interface{}
+
+	DontParseLinks  bool
+	DisableMentions bool
+	Intent          string
+	SubscribeID     int
+}
+
+// EditParams params of messages.edit
+type EditParams struct {
+	PeerID int
+
+	// MessageID id of message, either MessageID or ConversationMessageID required
+	MessageID             int
+	ConversationMessageID int
+
+	Message     string
+	Lat         float64
+	Long        float64
+	Attachments []string
+
+	KeepForwardMessages bool
+	KeepSnippets        bool
+	Keyboard            *keyboard.Keyboard
+
+	// Template carousel template, marshaled to json unless it is string
+	Template interface{}
+
+	DontParseLinks  bool
+	DisableMentions bool
+}
+
+// SetActivityParams params of messages.setActivity
+type SetActivityParams struct {
+	PeerID  int
+	GroupID int
+
+	// Type activity type, e.g. typing or audiomessage
+	Type string
+}
+
+// MarkAsReadParams params of messages.markAsRead
+type MarkAsReadParams struct {
+	PeerID         int
+	GroupID        int
+	StartMessageID int
+
+	MarkConversationAsRead bool
+}
+
+// MessageEventAnswerParams params of messages.sendMessageEventAnswer
+type MessageEventAnswerParams struct {
+	EventID string
+	UserID  int
+	PeerID  int
+
+	// EventData action performed by user's client, nil only stops button loading
+	EventData EventData
+}
+
+// SendResult result of messages.send
+type SendResult struct {
+	// MessageID id of sent message when PeerID used
+	MessageID int
+
+	// Peers results per peer when PeerIDs used
+	Peers []PeerSendResult
+}
+
+// PeerSendResult result of messages.send for one of PeerIDs
+type PeerSendResult struct {
+	PeerID                int
+	MessageID             int
+	ConversationMessageID int
+
+	// Err not nil if message was not delivered to peer
+	Err error
+}
+
+// Send sends message with messages.send
+func (m *Messages) Send(ctx context.Context, p SendParams) (SendResult, error) {
+	params, err := p.params()
+	if err != nil {
+		return SendResult{}, err
+	}
+	resp, err := m.api.CallMethodContext(ctx, "messages.send", params)
+	if err != nil {
+		return SendResult{}, err
+	}
+	if len(p.PeerIDs) == 0 {
+		return SendResult{MessageID: resp.Int("response")}, nil
+	}
+	res := SendResult{}
+	for _, r := range resp.Objects("response") {
+		pr := PeerSendResult{
+			PeerID:                r.Int("peer_id"),
+			MessageID:             r.Int("message_id"),
+			ConversationMessageID: r.Int("conversation_message_id"),
+		}
+		if e, ok := r.ObjectIf("error"); ok {
+			pr.Err = &APIError{
+				Method:  "messages.send",
+				Code:    e.Int("code"),
+				Message: e.String("description"),
+			}
+		}
+		res.Peers = append(res.Peers, pr)
+	}
+	return res, nil
+}
+
+// Edit edits message with messages.edit
+func (m *Messages) Edit(ctx context.Context, p EditParams) error {
+	params, err := p.params()
+	if err != nil {
+		return err
+	}
+	_, err = m.api.CallMethodContext(ctx, "messages.edit", params)
+	return err
+}
+
+// SetActivity shows activity of community in conversation with messages.setActivity
+func (m *Messages) SetActivity(ctx context.Context, p SetActivityParams) error {
+	params := Params{"peer_id": p.PeerID, "type": p.Type}
+	setInt(params, "group_id", p.GroupID)
+	_, err := m.api.CallMethodContext(ctx, "messages.setActivity", params)
+	return err
+}
+
+// MarkAsRead marks messages as read with messages.markAsRead
+func (m *Messages) MarkAsRead(ctx context.Context, p MarkAsReadParams) error {
+	params := Params{"peer_id": p.PeerID}
+	setInt(params, "group_id", p.GroupID)
+	setInt(params, "start_message_id", p.StartMessageID)
+	setBool(params, "mark_conversation_as_read", p.MarkConversationAsRead)
+	_, err := m.api.CallMethodContext(ctx, "messages.markAsRead", params)
+	return err
+}
+
+// SendMessageEventAnswer answers callback button click with messages.sendMessageEventAnswer
+func (m *Messages) SendMessageEventAnswer(ctx context.Context, p MessageEventAnswerParams) error {
+	params := Params{"event_id": p.EventID, "user_id": p.UserID, "peer_id": p.PeerID}
+	if p.EventData != nil {
+		if err := setJSON(params, "event_data", p.EventData); err != nil {
+			return err
+		}
+	}
+	_, err := m.api.CallMethodContext(ctx, "messages.sendMessageEventAnswer", params)
+	return err
+}
+
+func (p SendParams) params() (Params, error) {
+	if p.PeerID != 0 && len(p.PeerIDs) > 0 {
+		return nil, fmt.Errorf("peer_id and peer_ids are mutually exclusive")
+	}
+	params := Params{}
+	setInt(params, "peer_id", p.PeerID)
+	if len(p.PeerIDs) > 0 {
+		params["peer_ids"] = p.PeerIDs
+	}
+	setInt(params, "user_id", p.UserID)
+	setInt(params, "chat_id", p.ChatID)
+	setString(params, "domain", p.Domain)
+
+	params["random_id"] = p.RandomID
+	if p.RandomID == 0 {
+		id, err := RandomID()
+		if err != nil {
+			return nil, err
+		}
+		params["random_id"] = id
+	}
+
+	setString(params, "message", p.Message)
+	setLocation(params, p.Lat, p.Long)
+	if len(p.Attachments) > 0 {
+		params["attachment"] = p.Attachments
+	}
+	setInt(params, "reply_to", p.ReplyTo)
+	if len(p.ForwardMessages) > 0 {
+		params["forward_messages"] = p.ForwardMessages
+	}
+	setInt(params, "sticker_id", p.StickerID)
+	setString(params, "intent", p.Intent)
+	setInt(params, "subscribe_id", p.SubscribeID)
+	setBool(params, "dont_parse_links", p.DontParseLinks)
+	setBool(params, "disable_mentions", p.DisableMentions)
+
+	if p.Forward != nil {
+		if err := setJSON(params, "forward", p.Forward); err != nil {
+			return nil, err
+		}
+	}
+	if p.Keyboard != nil {
+		if err := setJSON(params, "keyboard", p.Keyboard); err != nil {
+			return nil, err
+		}
+	}
+	if p.Template != nil {
+		if err := setJSON(params, "template", p.Template); err != nil {
+			return nil, err
+		}
+	}
+	if p.Payload != nil {
+		if err := setJSON(params, "payload", p.Payload); err != nil {
+			return nil, err
+		}
+	}
+	return params, nil
+}
+
+func (p EditParams) params() (Params, error) {
+	if p.MessageID == 0 && p.ConversationMessageID == 0 {
+		return nil, fmt.Errorf("message_id or conversation_message_id required")
+	}
+	params := Params{"peer_id": p.PeerID}
+	setInt(params, "message_id", p.MessageID)
+	setInt(params, "conversation_message_id", p.ConversationMessageID)
+	setString(params, "message", p.Message)
+	setLocation(params, p.Lat, p.Long)
+	if len(p.Attachments) > 0 {
+		params["attachment"] = p.Attachments
+	}
+	setBool(params, "keep_forward_messages", p.KeepForwardMessages)
+	setBool(params, "keep_snippets", p.KeepSnippets)
+	setBool(params, "dont_parse_links", p.DontParseLinks)
+	setBool(params, "disable_mentions", p.DisableMentions)
+
+	if p.Keyboard != nil {
+		if err := setJSON(params, "keyboard", p.Keyboard); err != nil {
+			return nil, err
+		}
+	}
+	if p.Template != nil {
+		if err := setJSON(params, "template", p.Template); err != nil {
+			return nil, err
+		}
+	}
+	return params, nil
+}
+
+// RandomID generates random_id for messages.send
+func RandomID() (int64, error) {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return 0, err
+	}
+	// vk api accepts int32, zero disables deduplication
+	id := int64(binary.BigEndian.Uint32(b[:]) & 0x7fffffff)
+	if id == 0 {
+		id = 1
+	}
+	return id, nil
+}
+
+func setInt(params Params, name string, v int) {
+	if v != 0 {
+		params[name] = v
+	}
+}
+
+func setString(params Params, name string, v string) {
+	if v != "" {
+		params[name] = v
+	}
+}
+
+func setBool(params Params, name string, v bool) {
+	if v {
+		params[name] = true
+	}
+}
+
+func setLocation(params Params, lat, long float64) {
+	if lat != 0 || long != 0 {
+		params["lat"] = lat
+		params["long"] = long
+	}
+}
+
+// setJSON sets json representation of v, strings are considered as json already
+func setJSON(params Params, name string, v interface{}) error {
+	if s, ok := v.(string); ok {
+		params[name] = s
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("can not marshal %s: %w", name, err)
+	}
+	params[name] = string(data)
+	return nil
+}
@@ -0,0 +1,182 @@
+package vkbot
+
+import (
+	"context"
+	"fmt"
+	"github.com/AndrewShukhtin/vkbot/keyboard"
+	"github.com/karlseguin/typed"
+	"testing"
+)
+
+type recordingVkAPI struct {
+	calls []recordedCall
+	resp  typed.Typed
+	err   error
+}
+
+type recordedCall struct {
+	MethodName string
+	Params     Params
+}
+
+func (api *recordingVkAPI) CallMethod(methodName string, params Params) (typed.Typed, error) {
+	return api.CallMethodContext(context.Background(), methodName, params)
+}
+
+func (api *recordingVkAPI) CallMethodContext(_ context.Context, methodName string, params Params) (typed.Typed, error) {
+	api.calls = append(api.calls, recordedCall{MethodName: methodName, Params: params})
+	return api.resp, api.err
+}
+
+func (api *recordingVkAPI) SetConfig(_ VkAPIConfig) {}
+
+func (api *recordingVkAPI) lastCall() recordedCall {
+	if len(api.calls) == 0 {
+		return recordedCall{}
+	}
+	return api.calls[len(api.calls)-1]
+}
+
+func TestMessages_Send(t *testing.T) {
+	api := &recordingVkAPI{resp: typed.Typed{"response": 42}}
+	k := keyboard.NewKeyboard(false, true)
+	k.AddButton(keyboard.NewButton(keyboard.NewTextAction("test"), "secondary"))
+
+	res, err := NewMessages(api).Send(context.Background(), SendParams{
+		PeerID:          1,
+		Message:         "test",
+		Attachments:     []string{"photo1_2", "doc3_4"},
+		ForwardMessages: []int{5, 6},
+		Keyboard:        k,
+		DontParseLinks:  true,
+	})
+	if err != nil {
+		t.Fatal("should not be error", err)
+	}
+	if res.MessageID != 42 {
+		t.Errorf("invalid message id %d", res.MessageID)
+	}
+
+	call := api.lastCall()
+	if call.MethodName != "messages.send" {
+		t.Error("should call messages.send", call.MethodName)
+	}
+	kJSON, _ := k.JSON()
+	expected := map[string]string{
+		"peer_id":          "1",
+		"message":          "test",
+		"attachment":       "photo1_2,doc3_4",
+		"forward_messages": "5,6",
+		"keyboard":         kJSON,
+		"dont_parse_links": "1",
+	}
+	values, err := call.Params.Values()
+	if err != nil {
+		t.Fatal("should not be error", err)
+	}
+	for k, v := range expected {
+		if values.Get(k) != v {
+			t.Errorf("param %s: expected %s, got %s", k, v, values.Get(k))
+		}
+	}
+	if id, ok := call.Params["random_id"].(int64); !ok || id <= 0 {
+		t.Error("random_id should be generated", call.Params["random_id"])
+	}
+	if _, ok := call.Params["peer_ids"]; ok {
+		t.Error("should not contain peer_ids")
+	}
+}
+
+func TestMessages_SendPeerIDs(t *testing.T) {
+	api := &recordingVkAPI{resp: typed.Typed{"response": []interface{}{
+		map[string]interface{}{"peer_id": 1, "message_id": 10, "conversation_message_id": 3},
+		map[string]interface{}{"peer_id": 2, "error": map[string]interface{}{"code": 901, "description": "denied"}},
+	}}}
+
+	res, err := NewMessages(api).Send(context.Background(), SendParams{
+		PeerIDs:  []int{1, 2},
+		RandomID: 7,
+		Message:  "test",
+	})
+	if err != nil {
+		t.Fatal("should not be error", err)
+	}
+	if v := api.lastCall().Params["random_id"]; v != int64(7) {
+		t.Error("random_id should be taken from params", v)
+	}
+	if len(res.Peers) != 2 {
+		t.Fatalf("should be 2 peer results, got %d", len(res.Peers))
+	}
+	if res.Peers[0].MessageID != 10 || res.Peers[0].ConversationMessageID != 3 || res.Peers[0].Err != nil {
+		t.Errorf("invalid first peer result %+v", res.Peers[0])
+	}
+	if !IsErrorCode(res.Peers[1].Err, ErrorCodeMessagesDenySend) {
+		t.Errorf("invalid second peer result %+v", res.Peers[1])
+	}
+}
+
+func TestMessages_SendError(t *testing.T) {
+	api := &recordingVkAPI{err: fmt.Errorf("test error")}
+	if _, err := NewMessages(api).Send(context.Background(), SendParams{PeerID: 1}); err == nil {
+		t.Error("should be error")
+	}
+}
+
+func TestMessages_SendPeerIDAndPeerIDs(t *testing.T) {
+	api := &recordingVkAPI{}
+	_, err := NewMessages(api).Send(context.Background(), SendParams{PeerID: 1, PeerIDs: []int{2, 3}})
+	if err == nil {
+		t.Error("should be error for both peer_id and peer_ids")
+	}
+	if len(api.calls) != 0 {
+		t.Error("should not be called vk api")
+	}
+}
+
+func TestMessages_Edit(t *testing.T) {
+	api := &recordingVkAPI{resp: typed.Typed{"response": 1}}
+	m := NewMessages(api)
+	if err := m.Edit(context.Background(), EditParams{PeerID: 1, Message: "test"}); err == nil {
+		t.Error("should be error without message id")
+	}
+	err := m.Edit(context.Background(), EditParams{
+		PeerID:                1,
+		ConversationMessageID: 2,
+		Message:               "test",
+		Template:              `{"type":"carousel"}`,
+		KeepForwardMessages:   true,
+	})
+	if err != nil {
+		t.Fatal("should not be error", err)
+	}
+	values, err := api.lastCall().Params.Values()
+	if err != nil {
+		t.Fatal("should not be error", err)
+	}
+	if values.Get("conversation_message_id") != "2" || values.Get("keep_forward_messages") != "1" {
+		t.Error("invalid params", values)
+	}
+	if values.Get("template") != `{"type":"carousel"}` {
+		t.Error("string template should be passed as is", values.Get("template"))
+	}
+	if _, ok := api.lastCall().Params["message_id"]; ok {
+		t.Error("should not contain message_id")
+	}
+}
+
+func TestRandomID(t *testing.T) {
+	seen := map[int64]bool{}
+	for i := 0; i < 100; i++ {
+		id, err := RandomID()
+		if err != nil {
+			t.Fatal("should not be error", err)
+		}
+		if id <= 0 || id > 1<<31-1 {
+			t.Error("random_id out of int32 range", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) < 90 {
+		t.Error("random_id should be random")
+	}
+}
@@ -0,0 +1,57 @@
+package vkbot
+
+// Middleware wraps HandleFunc with cross-cutting logic,
+// it may return without calling next to stop handling of event
+type Middleware func(next HandleFunc) HandleFunc
+
+// Use adds middleware applied to events of all types,
+// middleware added first is outermost, global middleware wraps middleware added by UseFor,
+// it should be called before Start
+func (bot *VkBot) Use(mw ...Middleware) {
+	bot.middleware = append(bot.middleware, mw...)
+}
+
+// UseFor adds middleware applied to events of eventType only, it should be called before Start
+func (bot *VkBot) UseFor(eventType string, mw ...Middleware) {
+	if bot.typeMiddleware == nil {
+		bot.typeMiddleware = make(map[string][]Middleware)
+	}
+	bot.typeMiddleware[eventType] = append(bot.typeMiddleware[eventType], mw...)
+}
+
+// buildChains wraps handlers with global and event type middleware once,
+// so state created by middleware persists between events
+func (bot *VkBot) buildChains() {
+	bot.chains = make(map[string]HandleFunc, len(bot.handlers)+len(bot.typeMiddleware))
+	for eventType, handler := range bot.handlers {
+		bot.chains[eventType] = bot.wrap(eventType, handler)
+	}
+	for eventType := range bot.typeMiddleware {
+		if _, ok := bot.chains[eventType]; !ok {
+			bot.chains[eventType] = bot.wrap(eventType, notFoundHandler)
+		}
+	}
+	bot.notFound = chain(notFoundHandler, bot.middleware)
+}
+
+// chainOf returns wrapped handler of eventType
+func (bot *VkBot) chainOf(eventType string) HandleFunc {
+	if h, ok := bot.chains[eventType]; ok {
+		return h
+	}
+	return bot.notFound
+}
+
+// wrap wraps handler of eventType with global and eventType middleware
+func (bot *VkBot) wrap(eventType string, handler HandleFunc) HandleFunc {
+	handler = chain(handler, bot.typeMiddleware[eventType])
+	return chain(handler, bot.middleware)
+}
+
+// chain wraps handler so that mw[0] is called first
+func chain(handler HandleFunc, mw []Middleware) HandleFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		handler = mw[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,110 @@
+package vkbot
+
+import (
+	"fmt"
+	"github.com/AndrewShukhtin/vkbot/event"
+	"reflect"
+	"testing"
+)
+
+func recordingMiddleware(name string, calls *[]string) Middleware {
+	return func(next HandleFunc) HandleFunc {
+		return func(c *Context) error {
+			*calls = append(*calls, name+" before")
+			err := next(c)
+			*calls = append(*calls, name+" after")
+			return err
+		}
+	}
+}
+
+func TestVkBot_UseOrdering(t *testing.T) {
+	var calls []string
+	bot := &VkBot{handlers: map[string]HandleFunc{}}
+	bot.UseFor(event.MessageNewType, recordingMiddleware("type", &calls))
+	bot.Use(recordingMiddleware("first", &calls), recordingMiddleware("second", &calls))
+	bot.UseFor(event.MessageEventType, recordingMiddleware("other type", &calls))
+	bot.EventHandler(event.MessageNewType, func(_ *Context) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+
+	bot.buildChains()
+	bot.handleEvent(newMessageNewEvent(t, "hi", ""))
+	expected := []string{
+		"first before", "second before", "type before",
+		"handler",
+		"type after", "second after", "first after",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Error("should be called in order", calls)
+	}
+}
+
+func TestVkBot_UseShortCircuit(t *testing.T) {
+	handled := false
+	var handlerErr error
+	bot := &VkBot{handlers: map[string]HandleFunc{}}
+	bot.Use(func(next HandleFunc) HandleFunc {
+		return func(c *Context) error {
+			handlerErr = next(c)
+			return handlerErr
+		}
+	})
+	bot.Use(func(next HandleFunc) HandleFunc {
+		return func(c *Context) error {
+			return fmt.Errorf("blocked")
+		}
+	})
+	bot.EventHandler(event.MessageNewType, func(_ *Context) error {
+		handled = true
+		return nil
+	})
+
+	bot.buildChains()
+	bot.handleEvent(newMessageNewEvent(t, "hi", ""))
+	if handled {
+		t.Error("should not be called handler")
+	}
+	if handlerErr == nil || handlerErr.Error() != "blocked" {
+		t.Error("should be passed error to outer middleware", handlerErr)
+	}
+}
+
+func TestVkBot_UseNotFoundHandler(t *testing.T) {
+	var err error
+	bot := &VkBot{handlers: map[string]HandleFunc{}}
+	bot.Use(func(next HandleFunc) HandleFunc {
+		return func(c *Context) error {
+			err = next(c)
+			return err
+		}
+	})
+	bot.buildChains()
+	bot.handleEvent(newMessageNewEvent(t, "hi", ""))
+	if err == nil {
+		t.Error("should be wrapped not found handler")
+	}
+}
+
+func TestVkBot_UseBuildsChainOnce(t *testing.T) {
+	created := 0
+	handled := 0
+	bot := &VkBot{handlers: map[string]HandleFunc{}}
+	bot.Use(func(next HandleFunc) HandleFunc {
+		created++
+		return func(c *Context) error {
+			handled++
+			return next(c)
+		}
+	})
+	bot.EventHandler(event.MessageNewType, func(_ *Context) error { return nil })
+	bot.buildChains()
+	built := created
+	for i := 0; i < 3; i++ {
+		bot.handleEvent(newMessageNewEvent(t, "hi", ""))
+	}
+	if created != built || handled != 3 {
+		t.Error("should not be created middleware for each event", built, created, handled)
+	}
+}
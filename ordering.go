@@ -0,0 +1,51 @@
+package vkbot
+
+import (
+	"github.com/AndrewShukhtin/vkbot/event"
+	"hash/fnv"
+)
+
+// Ordering mode of events processing
+type Ordering int
+
+const (
+	// NoOrdering events are handled by any free worker, possibly out of order
+	NoOrdering Ordering = iota
+
+	// PeerOrdering events of one peer are handled strictly in order by the same worker,
+	// events of different peers are handled in parallel,
+	// peer is peer_id of message events and from_id or user_id of other events
+	PeerOrdering
+)
+
+// peerFields fields of event object identifying peer in order of priority
+var peerFields = []string{"peer_id", "from_id", "user_id"}
+
+// peerKey returns peer of event, zero if event has no peer
+func peerKey(e event.Event) int {
+	obj := e.Object()
+	if e.Type() == event.MessageNewType {
+		obj = obj.Object("message")
+	}
+	for _, f := range peerFields {
+		if id, ok := obj.IntIf(f); ok && id != 0 {
+			return id
+		}
+	}
+	return 0
+}
+
+// shardOf returns index of shard serving events of the same peer as e
+func shardOf(e event.Event, shards int) int {
+	if e == nil {
+		return 0
+	}
+	key := uint64(peerKey(e))
+	if key == 0 {
+		// events without peer are spread by event_id
+		h := fnv.New64a()
+		h.Write([]byte(e.EventID()))
+		key = h.Sum64()
+	}
+	return int(key % uint64(shards))
+}
@@ -0,0 +1,115 @@
+package vkbot
+
+import (
+	"fmt"
+	"github.com/AndrewShukhtin/vkbot/event"
+	"github.com/karlseguin/typed"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPeerKey(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Event event.Event
+		Key   int
+	}{
+		{
+			Name:  "message_new",
+			Event: newTestEvent(t, event.MessageNewType, typed.Typed{"message": typed.Typed{"peer_id": 1, "from_id": 2}}, 0, "1"),
+			Key:   1,
+		},
+		{
+			Name:  "message_event",
+			Event: newTestEvent(t, event.MessageEventType, typed.Typed{"peer_id": 3, "user_id": 4}, 0, "2"),
+			Key:   3,
+		},
+		{
+			Name:  "wall_reply_new",
+			Event: newTestEvent(t, event.WallReplyNewType, typed.Typed{"from_id": 5, "post_owner_id": -1}, 0, "3"),
+			Key:   5,
+		},
+		{
+			Name:  "group_join",
+			Event: newTestEvent(t, event.GroupJoinType, typed.Typed{"user_id": 6}, 0, "4"),
+			Key:   6,
+		},
+		{
+			Name:  "without peer",
+			Event: newTestEvent(t, event.DonutMoneyWithdrawType, typed.Typed{"amount": 1}, 0, "5"),
+			Key:   0,
+		},
+	}
+	for _, tc := range testCases {
+		if key := peerKey(tc.Event); key != tc.Key {
+			t.Error(tc.Name, "should be peer", tc.Key, "not", key)
+		}
+	}
+}
+
+func TestShardOf(t *testing.T) {
+	e1 := newTestEvent(t, event.MessageNewType, typed.Typed{"message": typed.Typed{"peer_id": 7}}, 0, "1")
+	e2 := newTestEvent(t, event.MessageNewType, typed.Typed{"message": typed.Typed{"peer_id": 7}}, 0, "2")
+	if shardOf(e1, 4) != shardOf(e2, 4) {
+		t.Error("should be the same shard for events of one peer")
+	}
+	e3 := newTestEvent(t, event.MessageNewType, typed.Typed{"message": typed.Typed{"peer_id": -7}}, 0, "3")
+	if s := shardOf(e3, 4); s < 0 || s >= 4 {
+		t.Error("should be valid shard for negative peer", s)
+	}
+	if shardOf(nil, 4) != 0 {
+		t.Error("should be the first shard for nil event")
+	}
+}
+
+func TestDispatcherPeerOrdering(t *testing.T) {
+	const peers, perPeer = 3, 10
+	d := newDispatcher(4, 2)
+	d.setOrdering(PeerOrdering)
+
+	mtx := &sync.Mutex{}
+	handled := map[int][]string{}
+	running := map[int]bool{}
+	wg := &sync.WaitGroup{}
+	wg.Add(peers * perPeer)
+	d.setWorkerFunc(func(e event.Event) {
+		defer wg.Done()
+		peer := peerKey(e)
+		mtx.Lock()
+		if running[peer] {
+			t.Error("should not be handled concurrently events of one peer")
+		}
+		running[peer] = true
+		mtx.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mtx.Lock()
+		running[peer] = false
+		handled[peer] = append(handled[peer], e.EventID())
+		mtx.Unlock()
+	})
+	d.startWorkers()
+	defer d.stopWorkers(func() {})
+
+	eventChan := make(chan event.Event)
+	go d.dispatch(eventChan)
+	for i := 0; i < perPeer; i++ {
+		for p := 1; p <= peers; p++ {
+			eventChan <- newTestEvent(t, event.MessageNewType,
+				typed.Typed{"message": typed.Typed{"peer_id": p}}, 0, fmt.Sprintf("%d", i))
+		}
+	}
+	close(eventChan)
+	wg.Wait()
+
+	for p := 1; p <= peers; p++ {
+		for i, id := range handled[p] {
+			if id != fmt.Sprintf("%d", i) {
+				t.Error("should be handled in order events of peer", p, handled[p])
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package vkbot
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/AndrewShukhtin/vkbot/event"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy defines what happens with event received when events queue is full
+type OverflowPolicy int
+
+const (
+	// BlockOnOverflow waits for free space in queue, so long poll is not polled meanwhile
+	BlockOnOverflow OverflowPolicy = iota
+
+	// DropOldest drops the oldest queued event to free space for received one
+	DropOldest
+
+	// DropNewest drops received event
+	DropNewest
+
+	// SpillToDisk writes received events to temporary file until queue has free space
+	SpillToDisk
+)
+
+// QueueStats metrics of events queue
+type QueueStats struct {
+	// Depth number of queued events including spilled to disk
+	Depth int
+
+	// Capacity max number of events queued in memory
+	Capacity int
+
+	// Spilled number of events currently spilled to disk
+	Spilled int
+
+	// Enqueued total number of events queued
+	Enqueued uint64
+
+	// Dropped total number of events dropped by DropOldest or DropNewest policy
+	Dropped uint64
+
+	// SpilledTotal total number of events spilled to disk
+	SpilledTotal uint64
+}
+
+func (s QueueStats) add(o QueueStats) QueueStats {
+	return QueueStats{
+		Depth:        s.Depth + o.Depth,
+		Capacity:     s.Capacity + o.Capacity,
+		Spilled:      s.Spilled + o.Spilled,
+		Enqueued:     s.Enqueued + o.Enqueued,
+		Dropped:      s.Dropped + o.Dropped,
+		SpilledTotal: s.SpilledTotal + o.SpilledTotal,
+	}
+}
+
+// eventQueue bounded queue of events read by workers with pop
+type eventQueue struct {
+	out    chan event.Event
+	policy OverflowPolicy
+	done   <-chan struct{}
+	onDrop func(event.Event)
+	// onLost called with number of spilled events which can not be read back
+	onLost func(count int)
+
+	// mtx serializes pushes with refill from spill in SpillToDisk mode and guards spilled
+	mtx     sync.Mutex
+	spill   *spillFile
+	spilled int
+	// refill signals refillLoop about spilled events or free space in queue
+	refill chan struct{}
+
+	enqueued     uint64
+	dropped      uint64
+	spilledTotal uint64
+}
+
+func newEventQueue(capacity int, policy OverflowPolicy, spillDir string, done <-chan struct{}) (*eventQueue, error) {
+	q := &eventQueue{
+		out:    make(chan event.Event, capacity),
+		policy: policy,
+		done:   done,
+	}
+	if policy == SpillToDisk {
+		spill, err := newSpillFile(spillDir)
+		if err != nil {
+			return nil, newInternalError(err, "can not create spill file in '%s'", spillDir)
+		}
+		q.spill = spill
+		q.refill = make(chan struct{}, 1)
+		go q.refillLoop()
+	}
+	return q, nil
+}
+
+// push queues event according to policy, it returns false if queue is done,
+// counters are updated before event is visible to workers and rolled back if it is not queued
+func (q *eventQueue) push(e event.Event) bool {
+	switch q.policy {
+	case DropNewest:
+		atomic.AddUint64(&q.enqueued, 1)
+		select {
+		case q.out <- e:
+		default:
+			atomic.AddUint64(&q.enqueued, ^uint64(0))
+			q.drop(e)
+		}
+		return true
+	case DropOldest:
+		atomic.AddUint64(&q.enqueued, 1)
+		for {
+			select {
+			case q.out <- e:
+				return true
+			default:
+			}
+			select {
+			case old := <-q.out:
+				q.drop(old)
+			default:
+			}
+		}
+	case SpillToDisk:
+		return q.pushOrSpill(e)
+	}
+	atomic.AddUint64(&q.enqueued, 1)
+	select {
+	case q.out <- e:
+		return true
+	case <-q.done:
+		atomic.AddUint64(&q.enqueued, ^uint64(0))
+		return false
+	}
+}
+
+func (q *eventQueue) pushOrSpill(e event.Event) bool {
+	q.mtx.Lock()
+	if q.spilled == 0 {
+		// events are spilled in order, so new event goes to disk while there are spilled ones
+		atomic.AddUint64(&q.enqueued, 1)
+		select {
+		case q.out <- e:
+			q.mtx.Unlock()
+			return true
+		default:
+			atomic.AddUint64(&q.enqueued, ^uint64(0))
+		}
+	}
+	if err := q.spill.write(e); err != nil {
+		q.mtx.Unlock()
+		// event is dropped, waiting for queue would let it overtake spilled events
+		logInternalErrorOr("can not spill event to disk, event dropped", err)
+		q.drop(e)
+		return true
+	}
+	q.spilled++
+	atomic.AddUint64(&q.spilledTotal, 1)
+	atomic.AddUint64(&q.enqueued, 1)
+	q.mtx.Unlock()
+	q.notifyRefill()
+	return true
+}
+
+// pop returns next queued event, it returns false if queue is done
+func (q *eventQueue) pop() (event.Event, bool) {
+	select {
+	case e := <-q.out:
+		// free space lets refill move next spilled event
+		q.notifyRefill()
+		return e, true
+	case <-q.done:
+		return nil, false
+	}
+}
+
+func (q *eventQueue) notifyRefill() {
+	if q.refill == nil {
+		return
+	}
+	select {
+	case q.refill <- struct{}{}:
+	default:
+	}
+}
+
+// refillLoop moves spilled events back to queue in order
+func (q *eventQueue) refillLoop() {
+	defer func() {
+		q.mtx.Lock()
+		q.spill.close()
+		q.mtx.Unlock()
+	}()
+	for {
+		select {
+		case <-q.refill:
+		case <-q.done:
+			return
+		}
+		for q.refillOne() {
+		}
+	}
+}
+
+// refillOne moves the oldest spilled event to queue if it has free space, it returns false if nothing moved
+func (q *eventQueue) refillOne() bool {
+	q.mtx.Lock()
+	if q.spilled == 0 || len(q.out) == cap(q.out) {
+		q.mtx.Unlock()
+		return false
+	}
+	e, err := q.spill.read()
+	if err != nil {
+		// event is lost, spill file is reset to keep queue consistent
+		lost := q.spilled
+		q.spilled = 0
+		q.spill.reset()
+		atomic.AddUint64(&q.dropped, uint64(lost))
+		q.mtx.Unlock()
+		logInternalErrorOr("can not read spilled event", err)
+		if q.onLost != nil {
+			q.onLost(lost)
+		}
+		return false
+	}
+	q.spilled--
+	if q.spilled == 0 {
+		q.spill.reset()
+	}
+	// send does not block, queue has free space and pushes wait for mtx
+	q.out <- e
+	q.mtx.Unlock()
+	return true
+}
+
+func (q *eventQueue) drop(e event.Event) {
+	atomic.AddUint64(&q.dropped, 1)
+	if q.onDrop != nil {
+		q.onDrop(e)
+	}
+}
+
+func (q *eventQueue) stats() QueueStats {
+	// mtx makes spilled events moved to queue counted once
+	q.mtx.Lock()
+	depth, spilled := len(q.out)+q.spilled, q.spilled
+	q.mtx.Unlock()
+	return QueueStats{
+		Depth:        depth,
+		Capacity:     cap(q.out),
+		Spilled:      spilled,
+		Enqueued:     atomic.LoadUint64(&q.enqueued),
+		Dropped:      atomic.LoadUint64(&q.dropped),
+		SpilledTotal: atomic.LoadUint64(&q.spilledTotal),
+	}
+}
+
+// spillFile temporary file of events stored as json lines
+type spillFile struct {
+	w *os.File
+	r *os.File
+	b *bufio.Reader
+}
+
+func newSpillFile(dir string) (*spillFile, error) {
+	w, err := os.CreateTemp(dir, "vkbot-spill-*.jsonl")
+	if err != nil {
+		return nil, err
+	}
+	r, err := os.Open(w.Name())
+	if err != nil {
+		w.Close()
+		os.Remove(w.Name())
+		return nil, err
+	}
+	return &spillFile{w: w, r: r, b: bufio.NewReader(r)}, nil
+}
+
+func (f *spillFile) write(e event.Event) error {
+	data, err := json.Marshal(e.Data())
+	if err != nil {
+		return err
+	}
+	_, err = f.w.Write(append(data, '\n'))
+	return err
+}
+
+func (f *spillFile) read() (event.Event, error) {
+	line, err := f.b.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(line, &data); err != nil {
+		return nil, err
+	}
+	return event.NewEvent(data)
+}
+
+// reset truncates file when all spilled events are read
+func (f *spillFile) reset() {
+	if err := f.w.Truncate(0); err != nil {
+		return
+	}
+	f.w.Seek(0, 0)
+	f.r.Seek(0, 0)
+	f.b.Reset(f.r)
+}
+
+func (f *spillFile) close() {
+	f.r.Close()
+	f.w.Close()
+	os.Remove(f.w.Name())
+}
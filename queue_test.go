@@ -0,0 +1,270 @@
+package vkbot
+
+import (
+	"context"
+	"fmt"
+	"github.com/AndrewShukhtin/vkbot/event"
+	"github.com/karlseguin/typed"
+	"os"
+	"testing"
+	"time"
+)
+
+func newQueueTestEvent(t *testing.T, id int) event.Event {
+	object := typed.Typed{"message": typed.Typed{"peer_id": 1, "text": fmt.Sprintf("text %d", id)}}
+	return newTestEvent(t, event.MessageNewType, object, 1, fmt.Sprintf("%d", id))
+}
+
+func popQueued(t *testing.T, q *eventQueue) event.Event {
+	popped := make(chan event.Event, 1)
+	go func() {
+		e, _ := q.pop()
+		popped <- e
+	}()
+	select {
+	case e := <-popped:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("should be queued event")
+	}
+	return nil
+}
+
+func TestEventQueue_Block(t *testing.T) {
+	done := make(chan struct{})
+	q, _ := newEventQueue(1, BlockOnOverflow, "", done)
+	if !q.push(newQueueTestEvent(t, 1)) {
+		t.Error("should be queued event")
+	}
+	pushed := make(chan bool)
+	go func() {
+		pushed <- q.push(newQueueTestEvent(t, 2))
+	}()
+	select {
+	case <-pushed:
+		t.Error("should be blocked on full queue")
+	case <-time.After(time.Millisecond * 20):
+	}
+	close(done)
+	if <-pushed {
+		t.Error("should not be queued event after done")
+	}
+}
+
+func TestEventQueue_DropNewest(t *testing.T) {
+	var dropped []string
+	q, _ := newEventQueue(1, DropNewest, "", make(chan struct{}))
+	q.onDrop = func(e event.Event) { dropped = append(dropped, e.EventID()) }
+	q.push(newQueueTestEvent(t, 1))
+	q.push(newQueueTestEvent(t, 2))
+	if e := popQueued(t, q); e.EventID() != "1" {
+		t.Error("should be kept the oldest event", e.EventID())
+	}
+	if len(dropped) != 1 || dropped[0] != "2" {
+		t.Error("should be dropped the newest event", dropped)
+	}
+	if s := q.stats(); s.Dropped != 1 || s.Enqueued != 1 {
+		t.Error("should be counted dropped events", s)
+	}
+}
+
+func TestEventQueue_DropOldest(t *testing.T) {
+	var dropped []string
+	q, _ := newEventQueue(2, DropOldest, "", make(chan struct{}))
+	q.onDrop = func(e event.Event) { dropped = append(dropped, e.EventID()) }
+	for i := 1; i <= 3; i++ {
+		q.push(newQueueTestEvent(t, i))
+	}
+	if e := popQueued(t, q); e.EventID() != "2" {
+		t.Error("should be dropped the oldest event", e.EventID())
+	}
+	if len(dropped) != 1 || dropped[0] != "1" {
+		t.Error("should be dropped the oldest event", dropped)
+	}
+}
+
+func TestEventQueue_SpillToDisk(t *testing.T) {
+	dir := t.TempDir()
+	done := make(chan struct{})
+	q, err := newEventQueue(1, SpillToDisk, dir, done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 5; i++ {
+		q.push(newQueueTestEvent(t, i))
+	}
+	if s := q.stats(); s.Depth != 5 || s.SpilledTotal < 3 {
+		t.Error("should be spilled events", s)
+	}
+	for i := 1; i <= 5; i++ {
+		e := popQueued(t, q)
+		if e.EventID() != fmt.Sprintf("%d", i) {
+			t.Error("should be queued in order", i, e.EventID())
+		}
+		mn, err := e.AsMessageNew()
+		if err != nil || mn.Message.Text != fmt.Sprintf("text %d", i) || mn.Message.PeerID != 1 {
+			t.Error("should be restored spilled event", mn, err)
+		}
+	}
+	if s := q.stats(); s.Depth != 0 || s.Spilled != 0 {
+		t.Error("should be empty queue", s)
+	}
+
+	close(done)
+	time.Sleep(time.Millisecond * 20)
+	files, _ := os.ReadDir(dir)
+	if len(files) != 0 {
+		t.Error("should be removed spill file")
+	}
+}
+
+func TestEventQueue_SpillWriteError(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	q, err := newEventQueue(1, SpillToDisk, t.TempDir(), done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dropped []string
+	q.onDrop = func(e event.Event) { dropped = append(dropped, e.EventID()) }
+	q.push(newQueueTestEvent(t, 1))
+	q.mtx.Lock()
+	q.spill.w.Close()
+	q.mtx.Unlock()
+
+	pushed := make(chan bool)
+	go func() {
+		pushed <- q.push(newQueueTestEvent(t, 2))
+	}()
+	select {
+	case ok := <-pushed:
+		if !ok {
+			t.Error("should not be done queue")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("should not be blocked on full queue")
+	}
+	if len(dropped) != 1 || dropped[0] != "2" {
+		t.Error("should be dropped not spilled event", dropped)
+	}
+	if e := popQueued(t, q); e.EventID() != "1" {
+		t.Error("should be kept queued event", e.EventID())
+	}
+}
+
+func TestEventQueue_SpillReadError(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+	q, err := newEventQueue(1, SpillToDisk, t.TempDir(), done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lost := make(chan int, 1)
+	q.onLost = func(count int) { lost <- count }
+
+	// corrupted spill file
+	q.mtx.Lock()
+	q.spill.w.Write([]byte("not json\n"))
+	q.spilled++
+	q.mtx.Unlock()
+	q.refill <- struct{}{}
+
+	select {
+	case count := <-lost:
+		if count != 1 {
+			t.Error("should be reported lost event", count)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("should be reported lost events")
+	}
+	if s := q.stats(); s.Dropped != 1 || s.Spilled != 0 {
+		t.Error("should be counted lost events", s)
+	}
+}
+
+func TestEventQueue_SpillToDiskInvalidDir(t *testing.T) {
+	_, err := newEventQueue(1, SpillToDisk, "/not/existing/dir", make(chan struct{}))
+	if err == nil {
+		t.Error("should be error")
+	}
+}
+
+func TestDispatcher_QueueStats(t *testing.T) {
+	d := newDispatcher(2, 3)
+	d.setQueue(0, DropNewest, "")
+	d.setWorkerFunc(func(_ event.Event) {})
+	if err := d.startWorkers(); err != nil {
+		t.Fatal(err)
+	}
+	defer d.stopWorkers(func() {})
+	if s := d.stats(); s.Capacity != 6 {
+		t.Error("should be Workers * WorkerBuffer capacity", s.Capacity)
+	}
+
+	d = newDispatcher(2, 3)
+	d.setOrdering(PeerOrdering)
+	d.setQueue(10, BlockOnOverflow, "")
+	d.setWorkerFunc(func(_ event.Event) {})
+	d.startWorkers()
+	defer d.stopWorkers(func() {})
+	if s := d.stats(); s.Capacity != 10 || len(d.queues) != 2 {
+		t.Error("should be queue of each worker", s.Capacity, len(d.queues))
+	}
+}
+
+func TestVkBot_ShutdownWithDroppedEvents(t *testing.T) {
+	bot, _ := newTestBotWithEvents(t, 5)
+	bot.config = BotConfig{Workers: 1, QueueSize: 1, Overflow: DropNewest, Events: 5}
+	started := make(chan struct{}, 5)
+	release := make(chan struct{})
+	bot.EventHandler(event.MessageNewType, func(_ *Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	go bot.Start()
+	<-started
+	for bot.QueueStats().Dropped == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := bot.Shutdown(ctx); err != nil {
+		t.Error("should not be waited for dropped events", err)
+	}
+	if s := bot.QueueStats(); s.Dropped+s.Enqueued != 5 {
+		t.Error("should be counted all events", s)
+	}
+}
+
+func TestVkBot_QueueStatsWhileStarting(t *testing.T) {
+	bot, _ := newTestBotWithEvents(t, 1)
+	bot.EventHandler(event.MessageNewType, func(_ *Context) error { return nil })
+	stop := make(chan struct{})
+	polled := make(chan struct{})
+	go func() {
+		defer close(polled)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				bot.QueueStats()
+			}
+		}
+	}()
+	go bot.Start()
+	for bot.QueueStats().Enqueued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	<-polled
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := bot.Shutdown(ctx); err != nil {
+		t.Error("should be shut down", err)
+	}
+}
@@ -0,0 +1,112 @@
+package vkbot
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+	"sync"
+	"time"
+)
+
+// Request rate limits of vk api per token
+const (
+	// GroupTokenRate max requests per second for group token
+	GroupTokenRate = 20
+	// UserTokenRate max requests per second for user token
+	UserTokenRate = 3
+	// NoRateLimit disables client-side rate limiting
+	NoRateLimit = float64(rate.Inf)
+)
+
+// RateLimit configures client-side limiting of outgoing requests per token
+type RateLimit struct {
+	// Rate max number of requests per second
+	Rate float64
+
+	// Burst max number of requests sent at once
+	Burst int
+
+	// MaxWait max time for call to wait in queue, zero means no limit
+	MaxWait time.Duration
+
+	// OnDelay called for every allowed method call with time it was delayed by limiter, zero if not delayed
+	OnDelay func(methodName string, delay time.Duration)
+}
+
+// withDefaults returns rate limit with invalid fields replaced by defaults of token type
+func (r RateLimit) withDefaults(userToken bool) RateLimit {
+	def := defaultRateLimit(userToken)
+	if r.Rate <= 0 {
+		r.Rate = def.Rate
+	}
+	if r.Burst < 1 {
+		r.Burst = def.Burst
+	}
+	if r.MaxWait < 0 {
+		r.MaxWait = 0
+	}
+	return r
+}
+
+type tokenLimiter struct {
+	config   RateLimit
+	limiters map[string]*rate.Limiter
+	mtx      *sync.Mutex
+}
+
+func newTokenLimiter(config RateLimit) *tokenLimiter {
+	return &tokenLimiter{
+		config:   config,
+		limiters: make(map[string]*rate.Limiter),
+		mtx:      &sync.Mutex{},
+	}
+}
+
+func (l *tokenLimiter) limiter(token string) *rate.Limiter {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	lim, ok := l.limiters[token]
+	if !ok {
+		lim = rate.NewLimiter(rate.Limit(l.config.Rate), l.config.Burst)
+		l.limiters[token] = lim
+	}
+	return lim
+}
+
+// wait blocks until call with token is allowed by limiter
+func (l *tokenLimiter) wait(ctx context.Context, token string, methodName string) error {
+	r := l.limiter(token).Reserve()
+	if !r.OK() {
+		return fmt.Errorf("%w: burst exceeded", ErrThrottled)
+	}
+	delay := r.Delay()
+	if l.config.MaxWait > 0 && delay > l.config.MaxWait {
+		r.Cancel()
+		return fmt.Errorf("%w: %s should wait %v", ErrThrottled, methodName, delay)
+	}
+	if l.config.OnDelay != nil {
+		l.config.OnDelay(methodName, delay)
+	}
+	if delay == 0 {
+		return nil
+	}
+	Logger.Debug(fmt.Sprintf("%s delayed by rate limiter", methodName), zap.Duration("delay", delay))
+	if err := sleepContext(ctx, delay); err != nil {
+		r.Cancel()
+		return err
+	}
+	return nil
+}
+
+// defaultRateLimit returns limit of user or group token
+func defaultRateLimit(userToken bool) RateLimit {
+	limit := GroupTokenRate
+	if userToken {
+		limit = UserTokenRate
+	}
+	return RateLimit{
+		Rate:  float64(limit),
+		Burst: 1,
+	}
+}
@@ -0,0 +1,86 @@
+package vkbot
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTokenLimiter_wait(t *testing.T) {
+	var delays []time.Duration
+	l := newTokenLimiter(RateLimit{
+		Rate:  100,
+		Burst: 1,
+		OnDelay: func(_ string, d time.Duration) {
+			delays = append(delays, d)
+		},
+	})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := l.wait(context.Background(), "token", "test.Tests"); err != nil {
+			t.Fatal("should not be error", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("calls should be delayed, elapsed %v", elapsed)
+	}
+	if len(delays) != 3 {
+		t.Fatalf("should report delay of every call, got %d", len(delays))
+	}
+	if delays[0] != 0 || delays[1] == 0 || delays[2] == 0 {
+		t.Error("should be delayed calls after first", delays)
+	}
+}
+
+func TestTokenLimiter_separateTokens(t *testing.T) {
+	l := newTokenLimiter(RateLimit{Rate: 1, Burst: 1, MaxWait: time.Millisecond})
+	if err := l.wait(context.Background(), "first", "test.Tests"); err != nil {
+		t.Error("should not be error", err)
+	}
+	if err := l.wait(context.Background(), "second", "test.Tests"); err != nil {
+		t.Error("other token should not be limited", err)
+	}
+	if err := l.wait(context.Background(), "first", "test.Tests"); !errors.Is(err, ErrThrottled) {
+		t.Error("should be throttled", err)
+	}
+}
+
+func TestTokenLimiter_cancellation(t *testing.T) {
+	l := newTokenLimiter(RateLimit{Rate: 1, Burst: 1})
+	if err := l.wait(context.Background(), "token", "test.Tests"); err != nil {
+		t.Error("should not be error", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx, "token", "test.Tests"); err != context.DeadlineExceeded {
+		t.Error("should be deadline exceeded", err)
+	}
+}
+
+func TestVkAPI_SetConfigRateLimit(t *testing.T) {
+	api := NewVkAPI("token").(*vkAPI)
+	api.SetConfig(VkAPIConfig{RateLimit: RateLimit{Rate: -1, Burst: 0, MaxWait: -1}})
+	cfg := api.config.RateLimit
+	if cfg.Rate != GroupTokenRate || cfg.Burst != 1 || cfg.MaxWait != 0 {
+		t.Errorf("invalid rate limit settings: %+v", cfg)
+	}
+	if api.limiter == nil || api.limiter.config.Rate != GroupTokenRate {
+		t.Error("limiter should be recreated")
+	}
+}
+
+func TestVkAPI_UserTokenRateLimit(t *testing.T) {
+	api := NewUserVkAPI("token").(*vkAPI)
+	if api.config.RateLimit.Rate != UserTokenRate || api.limiter.config.Rate != UserTokenRate {
+		t.Error("should be user token rate", api.config.RateLimit)
+	}
+	api.SetConfig(VkAPIConfig{RateLimit: RateLimit{Burst: 3}})
+	if cfg := api.config.RateLimit; cfg.Rate != UserTokenRate || cfg.Burst != 3 {
+		t.Error("should be default user token rate", cfg)
+	}
+	if NewVkAPI("token").(*vkAPI).config.RateLimit.Rate != GroupTokenRate {
+		t.Error("should be group token rate")
+	}
+}
@@ -0,0 +1,238 @@
+package vkbot
+
+import (
+	"context"
+	"fmt"
+	"github.com/AndrewShukhtin/vkbot/event"
+	"github.com/AndrewShukhtin/vkbot/keyboard"
+)
+
+// ReplyOption modifies params of message sent by Context.Reply
+type ReplyOption func(p *replyParams)
+
+// WithKeyboard attaches keyboard to reply
+func WithKeyboard(k *keyboard.Keyboard) ReplyOption {
+	return func(p *replyParams) {
+		p.Keyboard = k
+	}
+}
+
+// WithAttachments attaches media, e.g. photo1_2, to reply
+func WithAttachments(attachments ...string) ReplyOption {
+	return func(p *replyParams) {
+		p.Attachments = append(p.Attachments, attachments...)
+	}
+}
+
+// WithPayload sets payload of reply
+func WithPayload(payload interface{}) ReplyOption {
+	return func(p *replyParams) {
+		p.Payload = payload
+	}
+}
+
+// Quote makes reply quote message of event
+func Quote() ReplyOption {
+	return func(p *replyParams) {
+		p.quote = true
+	}
+}
+
+// replyParams params of reply modified by options
+type replyParams struct {
+	SendParams
+
+	// quote makes reply quote message of event
+	quote bool
+}
+
+// replyTarget conversation of event replied by Context helpers
+type replyTarget struct {
+	peerID                int
+	userID                int
+	messageID             int
+	conversationMessageID int
+
+	// callbackEventID event_id of callback button click
+	callbackEventID string
+}
+
+// Reply sends message with text to conversation of event, it returns id of sent message
+func (c *Context) Reply(text string, opts ...ReplyOption) (int, error) {
+	t, err := c.replyTarget()
+	if err != nil {
+		return 0, err
+	}
+	p := replyParams{SendParams: SendParams{PeerID: t.peerID, Message: text}}
+	for _, opt := range opts {
+		opt(&p)
+	}
+	if p.quote {
+		if t.conversationMessageID == 0 {
+			return 0, fmt.Errorf("%w: '%s' event has no message to quote", ErrNoConversation, c.Type())
+		}
+		p.Forward = &Forward{
+			PeerID:                 t.peerID,
+			ConversationMessageIDs: []int{t.conversationMessageID},
+			IsReply:                true,
+		}
+	}
+	return c.send(p.SendParams)
+}
+
+// ReplyWithKeyboard sends message with text and keyboard to conversation of event
+func (c *Context) ReplyWithKeyboard(text string, k *keyboard.Keyboard, opts ...ReplyOption) (int, error) {
+	return c.Reply(text, append([]ReplyOption{WithKeyboard(k)}, opts...)...)
+}
+
+// Edit edits message of event, e.g. message with callback button,
+// PeerID and ConversationMessageID are filled from event if zero
+func (c *Context) Edit(p EditParams) error {
+	t, err := c.replyTarget()
+	if err != nil {
+		return err
+	}
+	if p.PeerID == 0 {
+		p.PeerID = t.peerID
+	}
+	if p.MessageID == 0 && p.ConversationMessageID == 0 {
+		p.ConversationMessageID = t.conversationMessageID
+	}
+	m, err := c.messages()
+	if err != nil {
+		return err
+	}
+	return m.Edit(c, p)
+}
+
+// Forward forwards message of event to peerID, it returns id of sent message
+func (c *Context) Forward(peerID int, text string) (int, error) {
+	t, err := c.replyTarget()
+	if err != nil {
+		return 0, err
+	}
+	if t.conversationMessageID == 0 {
+		return 0, fmt.Errorf("%w: '%s' event has no message to forward", ErrNoConversation, c.Type())
+	}
+	return c.send(SendParams{
+		PeerID:  peerID,
+		Message: text,
+		Forward: &Forward{
+			PeerID:                 t.peerID,
+			ConversationMessageIDs: []int{t.conversationMessageID},
+		},
+	})
+}
+
+// SetActivity shows activity, e.g. typing, in conversation of event
+func (c *Context) SetActivity(activity string) error {
+	t, err := c.replyTarget()
+	if err != nil {
+		return err
+	}
+	m, err := c.messages()
+	if err != nil {
+		return err
+	}
+	return m.SetActivity(c, SetActivityParams{PeerID: t.peerID, GroupID: c.GroupID(), Type: activity})
+}
+
+// MarkAsRead marks conversation of event as read
+func (c *Context) MarkAsRead() error {
+	t, err := c.replyTarget()
+	if err != nil {
+		return err
+	}
+	m, err := c.messages()
+	if err != nil {
+		return err
+	}
+	return m.MarkAsRead(c, MarkAsReadParams{
+		PeerID:                 t.peerID,
+		GroupID:                c.GroupID(),
+		StartMessageID:         t.messageID,
+		MarkConversationAsRead: t.messageID == 0,
+	})
+}
+
+// AnswerCallback answers callback button click of message_event event,
+// data is ShowSnackbar, OpenLink or OpenApp, nil only stops button loading
+func (c *Context) AnswerCallback(data EventData) error {
+	return c.answerCallback(c, data)
+}
+
+func (c *Context) answerCallback(ctx context.Context, data EventData) error {
+	t, err := c.replyTarget()
+	if err != nil {
+		return err
+	}
+	if t.callbackEventID == "" {
+		return fmt.Errorf("%w: '%s' event is not callback button click", event.ErrTypeMismatch, c.Type())
+	}
+	m, err := c.messages()
+	if err != nil {
+		return err
+	}
+	// callback can be answered once, so failed answer is not repeated by auto answer
+	c.answered = true
+	return m.SendMessageEventAnswer(ctx, MessageEventAnswerParams{
+		EventID:   t.callbackEventID,
+		UserID:    t.userID,
+		PeerID:    t.peerID,
+		EventData: data,
+	})
+}
+
+func (c *Context) send(p SendParams) (int, error) {
+	m, err := c.messages()
+	if err != nil {
+		return 0, err
+	}
+	res, err := m.Send(c, p)
+	return res.MessageID, err
+}
+
+func (c *Context) messages() (*Messages, error) {
+	if c.api == nil {
+		return nil, ErrNoVkAPI
+	}
+	return NewMessages(c.api), nil
+}
+
+// replyTarget returns conversation of event, it is decoded once per Context
+func (c *Context) replyTarget() (*replyTarget, error) {
+	if c.target != nil {
+		return c.target, nil
+	}
+	t := &replyTarget{}
+	switch c.Type() {
+	case event.MessageNewType:
+		mn, err := c.AsMessageNew()
+		if err != nil {
+			return nil, err
+		}
+		t.peerID, t.userID = mn.Message.PeerID, mn.Message.FromID
+		t.messageID, t.conversationMessageID = mn.Message.ID, mn.Message.ConversationMessageID
+	case event.MessageReplyType, event.MessageEditType:
+		msg, err := c.AsMessage()
+		if err != nil {
+			return nil, err
+		}
+		t.peerID, t.userID = msg.PeerID, msg.FromID
+		t.messageID, t.conversationMessageID = msg.ID, msg.ConversationMessageID
+	case event.MessageEventType:
+		me, err := c.AsMessageEvent()
+		if err != nil {
+			return nil, err
+		}
+		t.peerID, t.userID = me.PeerID, me.UserID
+		t.conversationMessageID, t.callbackEventID = me.ConversationMessageID, me.EventID
+	default:
+		return nil, fmt.Errorf("%w: '%s' event", ErrNoConversation, c.Type())
+	}
+	if t.peerID == 0 {
+		return nil, fmt.Errorf("%w: '%s' event has no peer_id", ErrNoConversation, c.Type())
+	}
+	c.target = t
+	return t, nil
+}
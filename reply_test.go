@@ -0,0 +1,156 @@
+package vkbot
+
+import (
+	"context"
+	"errors"
+	"github.com/AndrewShukhtin/vkbot/event"
+	"github.com/AndrewShukhtin/vkbot/keyboard"
+	"github.com/karlseguin/typed"
+	"testing"
+)
+
+func newReplyContext(t *testing.T, api VkAPI, eventType string, object map[string]interface{}) *Context {
+	return NewContext(context.Background(), api, newTestEvent(t, eventType, object, 7, "test_event_id"))
+}
+
+func newMessageNewReplyContext(t *testing.T, api VkAPI) *Context {
+	return newReplyContext(t, api, event.MessageNewType, map[string]interface{}{
+		"message": map[string]interface{}{
+			"id":                      10,
+			"peer_id":                 2000000001,
+			"from_id":                 3,
+			"conversation_message_id": 5,
+			"text":                    "hi",
+		},
+	})
+}
+
+func newMessageEventReplyContext(t *testing.T, api VkAPI) *Context {
+	return newReplyContext(t, api, event.MessageEventType, map[string]interface{}{
+		"user_id":                 3,
+		"peer_id":                 3,
+		"event_id":                "callback_id",
+		"conversation_message_id": 8,
+	})
+}
+
+func checkParams(t *testing.T, call recordedCall, method string, expected map[string]string) {
+	t.Helper()
+	if call.MethodName != method {
+		t.Errorf("should call %s, called %s", method, call.MethodName)
+	}
+	values, err := call.Params.Values()
+	if err != nil {
+		t.Fatal("should not be error", err)
+	}
+	for k, v := range expected {
+		if values.Get(k) != v {
+			t.Errorf("param %s: expected %s, got %s", k, v, values.Get(k))
+		}
+	}
+}
+
+func TestContext_Reply(t *testing.T) {
+	api := &recordingVkAPI{resp: typed.Typed{"response": 42}}
+	c := newMessageNewReplyContext(t, api)
+	k := keyboard.NewKeyboard(false, true)
+	k.AddButton(keyboard.NewButton(keyboard.NewTextAction("test"), "secondary"))
+
+	id, err := c.Reply("hello", WithAttachments("photo1_2"), Quote())
+	if err != nil || id != 42 {
+		t.Fatal("should be sent reply", id, err)
+	}
+	checkParams(t, api.lastCall(), "messages.send", map[string]string{
+		"peer_id":    "2000000001",
+		"message":    "hello",
+		"attachment": "photo1_2",
+		"forward":    `{"peer_id":2000000001,"conversation_message_ids":[5],"is_reply":true}`,
+	})
+
+	if _, err := c.ReplyWithKeyboard("menu", k); err != nil {
+		t.Fatal("should be sent reply with keyboard", err)
+	}
+	kJSON, _ := k.JSON()
+	checkParams(t, api.lastCall(), "messages.send", map[string]string{
+		"peer_id":  "2000000001",
+		"message":  "menu",
+		"keyboard": kJSON,
+	})
+	if _, ok := api.lastCall().Params["forward"]; ok {
+		t.Error("should not be quoted reply without option")
+	}
+}
+
+func TestContext_Forward(t *testing.T) {
+	api := &recordingVkAPI{resp: typed.Typed{"response": 1}}
+	c := newMessageNewReplyContext(t, api)
+	if _, err := c.Forward(4, "look"); err != nil {
+		t.Fatal("should be forwarded", err)
+	}
+	checkParams(t, api.lastCall(), "messages.send", map[string]string{
+		"peer_id": "4",
+		"message": "look",
+		"forward": `{"peer_id":2000000001,"conversation_message_ids":[5]}`,
+	})
+}
+
+func TestContext_SetActivityAndMarkAsRead(t *testing.T) {
+	api := &recordingVkAPI{resp: typed.Typed{"response": 1}}
+	c := newMessageNewReplyContext(t, api)
+	if err := c.SetActivity("typing"); err != nil {
+		t.Fatal(err)
+	}
+	checkParams(t, api.lastCall(), "messages.setActivity", map[string]string{
+		"peer_id":  "2000000001",
+		"group_id": "7",
+		"type":     "typing",
+	})
+	if err := c.MarkAsRead(); err != nil {
+		t.Fatal(err)
+	}
+	checkParams(t, api.lastCall(), "messages.markAsRead", map[string]string{
+		"peer_id":          "2000000001",
+		"group_id":         "7",
+		"start_message_id": "10",
+	})
+}
+
+func TestContext_EditAndAnswerCallback(t *testing.T) {
+	api := &recordingVkAPI{resp: typed.Typed{"response": 1}}
+	c := newMessageEventReplyContext(t, api)
+	if err := c.Edit(EditParams{Message: "edited"}); err != nil {
+		t.Fatal(err)
+	}
+	checkParams(t, api.lastCall(), "messages.edit", map[string]string{
+		"peer_id":                 "3",
+		"conversation_message_id": "8",
+		"message":                 "edited",
+	})
+	if err := c.AnswerCallback(ShowSnackbar{Text: "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	checkParams(t, api.lastCall(), "messages.sendMessageEventAnswer", map[string]string{
+		"event_id":   "callback_id",
+		"user_id":    "3",
+		"peer_id":    "3",
+		"event_data": `{"text":"ok","type":"show_snackbar"}`,
+	})
+
+	if err := newMessageNewReplyContext(t, api).AnswerCallback(nil); !errors.Is(err, event.ErrTypeMismatch) {
+		t.Error("should not be answered not callback event", err)
+	}
+}
+
+func TestContext_ReplyErrors(t *testing.T) {
+	c := newReplyContext(t, &recordingVkAPI{}, event.GroupJoinType, map[string]interface{}{"user_id": 1})
+	if _, err := c.Reply("hi"); !errors.Is(err, ErrNoConversation) {
+		t.Error("should be error for event without conversation", err)
+	}
+	if _, err := newMessageNewReplyContext(t, nil).Reply("hi"); !errors.Is(err, ErrNoVkAPI) {
+		t.Error("should be error for context without api", err)
+	}
+	api := &recordingVkAPI{err: errors.New("api error")}
+	if _, err := newMessageNewReplyContext(t, api).Reply("hi"); err == nil {
+		t.Error("should be returned api error")
+	}
+}
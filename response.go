@@ -0,0 +1,163 @@
+package vkbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/karlseguin/typed"
+	"reflect"
+)
+
+// ResponseKind kind of json value in 'response' field
+type ResponseKind int
+
+// Kinds of json values vk api returns in 'response' field
+const (
+	NullResponse ResponseKind = iota
+	ObjectResponse
+	ArrayResponse
+	NumberResponse
+	StringResponse
+	BoolResponse
+)
+
+func (k ResponseKind) String() string {
+	switch k {
+	case ObjectResponse:
+		return "object"
+	case ArrayResponse:
+		return "array"
+	case NumberResponse:
+		return "number"
+	case StringResponse:
+		return "string"
+	case BoolResponse:
+		return "bool"
+	}
+	return "null"
+}
+
+// Response 'response' field of vk api reply of any shape
+type Response struct {
+	raw json.RawMessage
+}
+
+// NewResponse wraps raw 'response' field
+func NewResponse(raw json.RawMessage) *Response {
+	return &Response{raw: bytes.TrimSpace(raw)}
+}
+
+// CallResponse calls method by name with params and returns 'response' field as is
+func CallResponse(ctx context.Context, api VkAPI, methodName string, params Params) (*Response, error) {
+	raw, err := callRaw(ctx, api, methodName, params)
+	if err != nil {
+		return nil, err
+	}
+	return NewResponse(raw), nil
+}
+
+// Kind returns kind of json value
+func (r *Response) Kind() ResponseKind {
+	if len(r.raw) == 0 {
+		return NullResponse
+	}
+	switch r.raw[0] {
+	case '{':
+		return ObjectResponse
+	case '[':
+		return ArrayResponse
+	case '"':
+		return StringResponse
+	case 't', 'f':
+		return BoolResponse
+	case 'n':
+		return NullResponse
+	}
+	return NumberResponse
+}
+
+// Raw returns not decoded json value
+func (r *Response) Raw() json.RawMessage {
+	return r.raw
+}
+
+// Decode decodes response into v,
+// empty array is decoded as empty object since vk api returns it instead of empty object in some methods
+func (r *Response) Decode(v interface{}) error {
+	err := json.Unmarshal(r.raw, v)
+	if err == nil {
+		return nil
+	}
+	if bytes.Equal(r.raw, []byte("[]")) && isObjectType(reflect.TypeOf(v)) {
+		return nil
+	}
+	return fmt.Errorf("can not decode %s response into %T: %w", r.Kind(), v, err)
+}
+
+// Object returns object response
+func (r *Response) Object() (typed.Typed, error) {
+	if err := r.expect(ObjectResponse); err != nil {
+		return nil, err
+	}
+	return typed.Json(r.raw)
+}
+
+// Array returns array response with values of any type
+func (r *Response) Array() ([]interface{}, error) {
+	if err := r.expect(ArrayResponse); err != nil {
+		return nil, err
+	}
+	var res []interface{}
+	err := json.Unmarshal(r.raw, &res)
+	return res, err
+}
+
+// Objects returns array of objects response
+func (r *Response) Objects() ([]typed.Typed, error) {
+	if err := r.expect(ArrayResponse); err != nil {
+		return nil, err
+	}
+	var res []typed.Typed
+	if err := json.Unmarshal(r.raw, &res); err != nil {
+		return nil, fmt.Errorf("response is not an array of objects: %w", err)
+	}
+	return res, nil
+}
+
+// Int returns number response
+func (r *Response) Int() (int, error) {
+	if err := r.expect(NumberResponse); err != nil {
+		return 0, err
+	}
+	var res int
+	err := json.Unmarshal(r.raw, &res)
+	return res, err
+}
+
+// Text returns string response
+func (r *Response) Text() (string, error) {
+	if err := r.expect(StringResponse); err != nil {
+		return "", err
+	}
+	var res string
+	err := json.Unmarshal(r.raw, &res)
+	return res, err
+}
+
+// Bool returns bool response
+func (r *Response) Bool() (bool, error) {
+	if err := r.expect(BoolResponse); err != nil {
+		return false, err
+	}
+	var res bool
+	err := json.Unmarshal(r.raw, &res)
+	return res, err
+}
+
+func (r *Response) expect(kind ResponseKind) error {
+	if k := r.Kind(); k != kind {
+		return fmt.Errorf("response is %s, not %s", k, kind)
+	}
+	return nil
+}
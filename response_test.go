@@ -0,0 +1,181 @@
+package vkbot
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCallResponseShapes(t *testing.T) {
+	type TestCase struct {
+		Name     string
+		Body     string
+		Kind     ResponseKind
+		Expected interface{}
+	}
+	testCases := []TestCase{
+		{
+			Name:     "object",
+			Body:     `{"response": {"count": 1}}`,
+			Kind:     ObjectResponse,
+			Expected: map[string]interface{}{"count": float64(1)},
+		},
+		{
+			Name:     "array of objects",
+			Body:     `{"response": [{"id": 1}, {"id": 2}]}`,
+			Kind:     ArrayResponse,
+			Expected: []interface{}{map[string]interface{}{"id": float64(1)}, map[string]interface{}{"id": float64(2)}},
+		},
+		{
+			Name:     "array of numbers",
+			Body:     `{"response": [1, 2, 3]}`,
+			Kind:     ArrayResponse,
+			Expected: []interface{}{float64(1), float64(2), float64(3)},
+		},
+		{
+			Name:     "number",
+			Body:     `{"response": 1}`,
+			Kind:     NumberResponse,
+			Expected: 1,
+		},
+		{
+			Name:     "string",
+			Body:     `{"response": "test"}`,
+			Kind:     StringResponse,
+			Expected: "test",
+		},
+		{
+			Name:     "bool",
+			Body:     `{"response": true}`,
+			Kind:     BoolResponse,
+			Expected: true,
+		},
+		{
+			Name:     "null",
+			Body:     `{"response": null}`,
+			Kind:     NullResponse,
+			Expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		server := newResponseServer(tc.Body)
+		api := &vkAPI{URL: server.URL + "/", client: server.Client()}
+
+		resp, err := CallResponse(context.Background(), api, "test.Tests", Params{})
+		server.Close()
+		if err != nil {
+			t.Errorf("%s: should not be error: %v", tc.Name, err)
+			continue
+		}
+		if resp.Kind() != tc.Kind {
+			t.Errorf("%s: expected %s kind, got %s", tc.Name, tc.Kind, resp.Kind())
+			continue
+		}
+
+		var got interface{}
+		switch tc.Kind {
+		case ObjectResponse:
+			obj, err := resp.Object()
+			if err != nil {
+				t.Errorf("%s: %v", tc.Name, err)
+			}
+			got = map[string]interface{}(obj)
+		case ArrayResponse:
+			got, err = resp.Array()
+		case NumberResponse:
+			got, err = resp.Int()
+		case StringResponse:
+			got, err = resp.Text()
+		case BoolResponse:
+			got, err = resp.Bool()
+		}
+		if err != nil {
+			t.Errorf("%s: should not be error: %v", tc.Name, err)
+		}
+		if !reflect.DeepEqual(got, tc.Expected) {
+			t.Errorf("%s: expected %v, got %v", tc.Name, tc.Expected, got)
+		}
+	}
+}
+
+func TestResponseKindMismatch(t *testing.T) {
+	resp := NewResponse([]byte(` [{"id": 1}] `))
+	if _, err := resp.Object(); err == nil {
+		t.Error("should be error for array as object")
+	}
+	if _, err := resp.Int(); err == nil {
+		t.Error("should be error for array as number")
+	}
+	if _, err := resp.Text(); err == nil {
+		t.Error("should be error for array as string")
+	}
+	if _, err := resp.Bool(); err == nil {
+		t.Error("should be error for array as bool")
+	}
+	objects, err := resp.Objects()
+	if err != nil || len(objects) != 1 || objects[0].Int("id") != 1 {
+		t.Error("invalid objects", objects, err)
+	}
+	if _, err := NewResponse([]byte(`[1, 2]`)).Objects(); err == nil {
+		t.Error("should be error for array of numbers as objects")
+	}
+}
+
+func TestResponseDecode(t *testing.T) {
+	var users []testUser
+	if err := NewResponse([]byte(`[{"id": 1, "first_name": "Pavel"}]`)).Decode(&users); err != nil || len(users) != 1 {
+		t.Error("invalid decoded users", users, err)
+	}
+	var u testUser
+	if err := NewResponse([]byte(`[]`)).Decode(&u); err != nil {
+		t.Error("empty array should be decoded into object", err)
+	}
+	var n int
+	if err := NewResponse([]byte(`[]`)).Decode(&n); err == nil {
+		t.Error("should be error while decoding array into int")
+	}
+}
+
+func TestCallMethodShapes(t *testing.T) {
+	testCases := map[string]bool{
+		`{"response": {"count": 1}}`: true,
+		`{"response": [1, 2]}`:       true,
+		`{"response": [{"id": 1}]}`:  true,
+		`{"response": 1}`:            true,
+		`{"response": "test"}`:       true,
+		`{"response": true}`:         true,
+		`{"response": null}`:         false,
+		`{"result": 1}`:              false,
+	}
+	for body, ok := range testCases {
+		server := newResponseServer(body)
+		api := &vkAPI{URL: server.URL + "/", client: server.Client()}
+		_, err := api.CallMethod("test.Tests", Params{})
+		server.Close()
+		if ok && err != nil {
+			t.Errorf("%s: should not be error: %v", body, err)
+		}
+		if !ok && err == nil {
+			t.Errorf("%s: should be error", body)
+		}
+	}
+}
+
+func TestCallMethodScalarResponses(t *testing.T) {
+	server := newResponseServer(`{"response": "token"}`)
+	api := &vkAPI{URL: server.URL + "/", client: server.Client()}
+	resp, err := api.CallMethod("test.Tests", Params{})
+	server.Close()
+	if err != nil || resp.String("response") != "token" {
+		t.Error("should be string in response field", resp, err)
+	}
+
+	server = newResponseServer(`{"response": false}`)
+	api = &vkAPI{URL: server.URL + "/", client: server.Client()}
+	resp, err = api.CallMethod("test.Tests", Params{})
+	server.Close()
+	if v, ok := resp.BoolIf("response"); err != nil || !ok || v {
+		t.Error("should be bool in response field", resp, err)
+	}
+}
@@ -0,0 +1,104 @@
+package vkbot
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// RetryPolicy configures retries of transient vk api errors
+type RetryPolicy struct {
+	// MaxAttempts max number of attempts including the first one, 1 disables retries
+	MaxAttempts int
+
+	// MinBackoff delay before the first retry, it doubles with every next attempt
+	MinBackoff time.Duration
+
+	// MaxBackoff upper bound of delay between attempts
+	MaxBackoff time.Duration
+
+	// Jitter fraction of delay to randomize, from 0 to 1
+	Jitter float64
+
+	// RetryableCodes vk api error codes to retry, network errors are always retryable
+	RetryableCodes []int
+}
+
+// nonIdempotentMethods methods retried only with param making them safe to retry,
+// e.g. messages.send with random_id, methods with empty param name, e.g. execute, are never retried
+var nonIdempotentMethods = map[string]string{
+	"execute":              "",
+	"messages.send":        "random_id",
+	"wall.post":            "guid",
+	"wall.createComment":   "guid",
+	"board.createComment":  "guid",
+	"market.createComment": "guid",
+}
+
+// withDefaults returns policy with invalid fields replaced by defaults
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	def := defaultRetryPolicy()
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = def.MaxAttempts
+	}
+	if p.MinBackoff <= 0 {
+		p.MinBackoff = def.MinBackoff
+	}
+	if p.MaxBackoff < p.MinBackoff {
+		p.MaxBackoff = def.MaxBackoff
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		p.Jitter = def.Jitter
+	}
+	if p.RetryableCodes == nil {
+		p.RetryableCodes = def.RetryableCodes
+	}
+	return p
+}
+
+func (p RetryPolicy) allows(methodName string, params Params) bool {
+	if p.MaxAttempts < 2 {
+		return false
+	}
+	name, ok := nonIdempotentMethods[methodName]
+	if !ok {
+		return true
+	}
+	if name == "" {
+		return false
+	}
+	v, ok := params[name]
+	if !ok || v == nil {
+		return false
+	}
+	s := fmt.Sprintf("%v", v)
+	return s != "" && s != "0"
+}
+
+func (p RetryPolicy) isRetryable(err error) bool {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return true
+	}
+	return IsErrorCode(err, p.RetryableCodes...)
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	b := backoff{min: p.MinBackoff, max: p.MaxBackoff, jitter: p.Jitter}
+	return b.duration(attempt)
+}
+
+func defaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  200 * time.Millisecond,
+		MaxBackoff:  5 * time.Second,
+		Jitter:      0.2,
+		RetryableCodes: []int{
+			ErrorCodeTooManyRequests,
+			ErrorCodeFloodControl,
+			ErrorCodeInternalServerError,
+		},
+	}
+}
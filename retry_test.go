@@ -0,0 +1,218 @@
+package vkbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy_allows(t *testing.T) {
+	type TestCase struct {
+		Name       string
+		Policy     RetryPolicy
+		MethodName string
+		Params     Params
+		Allowed    bool
+	}
+	testCases := []TestCase{
+		{
+			Name:       "retries disabled",
+			Policy:     RetryPolicy{MaxAttempts: 1},
+			MethodName: "users.get",
+			Params:     Params{},
+			Allowed:    false,
+		},
+		{
+			Name:       "idempotent method",
+			Policy:     defaultRetryPolicy(),
+			MethodName: "users.get",
+			Params:     Params{},
+			Allowed:    true,
+		},
+		{
+			Name:       "messages.send without random_id",
+			Policy:     defaultRetryPolicy(),
+			MethodName: "messages.send",
+			Params:     Params{"peer_id": 1},
+			Allowed:    false,
+		},
+		{
+			Name:       "messages.send with zero random_id",
+			Policy:     defaultRetryPolicy(),
+			MethodName: "messages.send",
+			Params:     Params{"peer_id": 1, "random_id": 0},
+			Allowed:    false,
+		},
+		{
+			Name:       "messages.send with random_id",
+			Policy:     defaultRetryPolicy(),
+			MethodName: "messages.send",
+			Params:     Params{"peer_id": 1, "random_id": 42},
+			Allowed:    true,
+		},
+	}
+	for _, tc := range testCases {
+		if tc.Policy.allows(tc.MethodName, tc.Params) != tc.Allowed {
+			t.Errorf("%s: should be %v", tc.Name, tc.Allowed)
+		}
+	}
+}
+
+func newFlakyServer(failures int32, code int) (*httptest.Server, *int32) {
+	errorResponse := fmt.Sprintf(`{"error": {"error_code": %d, "error_msg": "test"}}`, code)
+	calls := new(int32)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(calls, 1) <= failures {
+				w.Write([]byte(errorResponse))
+				return
+			}
+			w.Write([]byte(`{"response": {"vk_object": "test"}}`))
+		}))
+	return server, calls
+}
+
+func TestVkApiRetry(t *testing.T) {
+	server, calls := newFlakyServer(2, ErrorCodeTooManyRequests)
+	defer server.Close()
+
+	api := &vkAPI{
+		URL:    server.URL + "/",
+		client: server.Client(),
+	}
+	api.SetConfig(VkAPIConfig{
+		Retry: RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond},
+	})
+
+	resp, err := api.CallMethod("test.Tests", Params{})
+	if err != nil {
+		t.Fatal("should not be error after retries", err)
+	}
+	if resp.String("vk_object") != "test" {
+		t.Error("invalid response", resp)
+	}
+	if n := atomic.LoadInt32(calls); n != 3 {
+		t.Errorf("should be 3 calls, got %d", n)
+	}
+}
+
+func TestVkApiRetryExhausted(t *testing.T) {
+	server, calls := newFlakyServer(5, ErrorCodeInternalServerError)
+	defer server.Close()
+
+	api := &vkAPI{
+		URL:    server.URL + "/",
+		client: server.Client(),
+	}
+	api.SetConfig(VkAPIConfig{
+		Retry: RetryPolicy{
+			MaxAttempts: 2,
+			MinBackoff:  time.Millisecond,
+			MaxBackoff:  time.Millisecond,
+		},
+	})
+
+	_, err := api.CallMethod("test.Tests", Params{})
+	if err == nil {
+		t.Error("should be error when attempts exhausted")
+	}
+	if n := atomic.LoadInt32(calls); n != 2 {
+		t.Errorf("should be 2 calls, got %d", n)
+	}
+}
+
+func TestVkApiNoRetryForNonIdempotent(t *testing.T) {
+	server, calls := newFlakyServer(1, ErrorCodeTooManyRequests)
+	defer server.Close()
+
+	api := &vkAPI{
+		URL:    server.URL + "/",
+		client: server.Client(),
+		config: defaultVkAPIConfig(),
+	}
+
+	_, err := api.CallMethod("messages.send", Params{"peer_id": 1})
+	if !IsErrorCode(err, ErrorCodeTooManyRequests) {
+		t.Error("should be too many requests error", err)
+	}
+	if n := atomic.LoadInt32(calls); n != 1 {
+		t.Errorf("should be 1 call, got %d", n)
+	}
+}
+
+func TestVkApiRetryCancellation(t *testing.T) {
+	server, _ := newFlakyServer(100, ErrorCodeTooManyRequests)
+	defer server.Close()
+
+	api := &vkAPI{
+		URL:    server.URL + "/",
+		client: server.Client(),
+	}
+	api.SetConfig(VkAPIConfig{
+		Retry: RetryPolicy{MaxAttempts: 5, MinBackoff: time.Hour, MaxBackoff: time.Hour},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := api.CallMethodContext(ctx, "test.Tests", Params{})
+	if err != context.DeadlineExceeded {
+		t.Error("should stop retrying when context is done", err)
+	}
+}
+
+func TestVkApiNoRetryOfCancelledRequest(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+		}))
+	defer server.Close()
+	defer close(release)
+
+	api := &vkAPI{
+		URL:    server.URL + "/",
+		client: server.Client(),
+	}
+	api.SetConfig(VkAPIConfig{
+		Retry: RetryPolicy{MaxAttempts: 5, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := api.CallMethodContext(ctx, "test.Tests", Params{})
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("should be returned error of cancelled request", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Error("should not be retried", n)
+	}
+}
+
+func TestVkApiSetConfigKeepsZeroSections(t *testing.T) {
+	solver := NewChanCaptchaSolver(time.Second)
+	api := NewVkAPI("token").(*vkAPI)
+	api.SetConfig(VkAPIConfig{
+		RateLimit:     RateLimit{Rate: 5, Burst: 2},
+		CaptchaSolver: solver,
+	})
+	api.SetConfig(VkAPIConfig{Retry: RetryPolicy{MaxAttempts: 5}})
+
+	if api.config.Retry.MaxAttempts != 5 || api.config.Retry.MinBackoff <= 0 {
+		t.Error("should be set retry policy with defaults", api.config.Retry)
+	}
+	if api.config.RateLimit.Rate != 5 || api.config.RateLimit.Burst != 2 {
+		t.Error("should be kept rate limit", api.config.RateLimit)
+	}
+	if api.config.CaptchaSolver != solver {
+		t.Error("should be kept captcha solver")
+	}
+}
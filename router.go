@@ -0,0 +1,176 @@
+package vkbot
+
+import (
+	"encoding/json"
+	"github.com/AndrewShukhtin/vkbot/event"
+	"github.com/karlseguin/typed"
+	"regexp"
+	"strings"
+)
+
+// PayloadCommandField field of button payload matched by Router.Payload
+const PayloadCommandField = "cmd"
+
+// mentionRegexp matches bot mention at the beginning of chat message, e.g. [club1|@bot]
+var mentionRegexp = regexp.MustCompile(`^\[(club|public)\d+\|[^\]]*\][\s,:]*`)
+
+type routeKind int
+
+const (
+	commandRoute routeKind = iota
+	textRoute
+	regexpRoute
+	payloadRoute
+)
+
+type route struct {
+	kind    routeKind
+	value   string
+	re      *regexp.Regexp
+	reFold  *regexp.Regexp
+	handler HandleFunc
+}
+
+// Router routes message_new and message_event events to handlers
+// by command, text, regexp or button payload,
+// routes are matched in order of adding, the first matched one handles event
+type Router struct {
+	routes     []route
+	fallback   HandleFunc
+	ignoreCase bool
+}
+
+// NewRouter creates empty Router
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// IgnoreCase makes matching of commands, texts, regexps and payloads case-insensitive
+func (r *Router) IgnoreCase(ignore bool) *Router {
+	r.ignoreCase = ignore
+	return r
+}
+
+// Command adds handler of messages starting with command, e.g. /start,
+// text after command is available with Context.Args
+func (r *Router) Command(cmd string, handler HandleFunc) *Router {
+	return r.add(route{kind: commandRoute, value: cmd, handler: handler})
+}
+
+// Text adds handler of messages with exactly the same text, surrounding spaces are ignored
+func (r *Router) Text(text string, handler HandleFunc) *Router {
+	return r.add(route{kind: textRoute, value: strings.TrimSpace(text), handler: handler})
+}
+
+// Regexp adds handler of messages matching pattern, captured groups are available with Context.Groups,
+// it panics if pattern is invalid
+func (r *Router) Regexp(pattern string, handler HandleFunc) *Router {
+	return r.add(route{
+		kind:    regexpRoute,
+		re:      regexp.MustCompile(pattern),
+		reFold:  regexp.MustCompile("(?i)" + pattern),
+		handler: handler,
+	})
+}
+
+// Payload adds handler of button clicks with payload field cmd equal to cmd,
+// both text buttons (message_new) and callback buttons (message_event) are matched
+func (r *Router) Payload(cmd string, handler HandleFunc) *Router {
+	return r.add(route{kind: payloadRoute, value: cmd, handler: handler})
+}
+
+// Fallback sets handler of events not matched by any route,
+// not matched events are ignored by default
+func (r *Router) Fallback(handler HandleFunc) *Router {
+	r.fallback = handler
+	return r
+}
+
+func (r *Router) add(rt route) *Router {
+	r.routes = append(r.routes, rt)
+	return r
+}
+
+// Handle routes event to matched handler, it is HandleFunc for VkBot.EventHandler
+func (r *Router) Handle(c *Context) error {
+	text, payload := routedFields(c.Event)
+	for _, rt := range r.routes {
+		if m, ok := r.match(rt, text, payload); ok {
+			c.match = m
+			return rt.handler(c)
+		}
+	}
+	if r.fallback != nil {
+		c.match = routeMatch{}
+		return r.fallback(c)
+	}
+	return nil
+}
+
+func (r *Router) match(rt route, text string, payload typed.Typed) (routeMatch, bool) {
+	switch rt.kind {
+	case commandRoute:
+		if len(text) < len(rt.value) || !r.equal(text[:len(rt.value)], rt.value) {
+			return routeMatch{}, false
+		}
+		rest := text[len(rt.value):]
+		if rest != "" && rest[0] != ' ' && rest[0] != '\n' {
+			// e.g. /starting is not /start command
+			return routeMatch{}, false
+		}
+		return routeMatch{args: strings.TrimSpace(rest)}, true
+	case textRoute:
+		return routeMatch{}, text != "" && r.equal(text, rt.value)
+	case regexpRoute:
+		re := rt.re
+		if r.ignoreCase {
+			re = rt.reFold
+		}
+		groups := re.FindStringSubmatch(text)
+		if groups == nil {
+			return routeMatch{}, false
+		}
+		named := make(map[string]string)
+		for i, name := range re.SubexpNames() {
+			if name != "" {
+				named[name] = groups[i]
+			}
+		}
+		return routeMatch{groups: groups, named: named}, true
+	case payloadRoute:
+		cmd, ok := payload.StringIf(PayloadCommandField)
+		return routeMatch{}, ok && r.equal(cmd, rt.value)
+	}
+	return routeMatch{}, false
+}
+
+func (r *Router) equal(a, b string) bool {
+	if r.ignoreCase {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// routedFields returns text and payload of message_new or message_event event
+func routedFields(e event.Event) (string, typed.Typed) {
+	obj := e.Object()
+	switch e.Type() {
+	case event.MessageEventType:
+		return "", obj.Object("payload")
+	case event.MessageNewType:
+		obj = obj.Object("message")
+	}
+	text := strings.TrimSpace(mentionRegexp.ReplaceAllString(obj.String("text"), ""))
+	payload := typed.Typed{}
+	if raw := obj.String("payload"); raw != "" {
+		// payload of text button is json string, invalid one is ignored
+		_ = json.Unmarshal([]byte(raw), &payload)
+	}
+	return text, payload
+}
+
+type routeMatch struct {
+	args   string
+	groups []string
+	named  map[string]string
+}
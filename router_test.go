@@ -0,0 +1,194 @@
+package vkbot
+
+import (
+	"context"
+	"github.com/AndrewShukhtin/vkbot/event"
+	"reflect"
+	"testing"
+)
+
+func newMessageNewEvent(t *testing.T, text string, payload string) event.Event {
+	e, err := event.NewEvent(map[string]interface{}{
+		"type": event.MessageNewType,
+		"object": map[string]interface{}{
+			"message": map[string]interface{}{
+				"text":    text,
+				"payload": payload,
+				"peer_id": 1,
+			},
+		},
+		"group_id": 1,
+		"event_id": "test_event_id",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func newMessageEventEvent(t *testing.T, payload map[string]interface{}) event.Event {
+	e, err := event.NewEvent(map[string]interface{}{
+		"type": event.MessageEventType,
+		"object": map[string]interface{}{
+			"payload": payload,
+			"peer_id": 1,
+		},
+		"group_id": 1,
+		"event_id": "test_event_id",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func newMessageNewContext(t *testing.T, text string, payload string) *Context {
+	return NewContext(context.Background(), nil, newMessageNewEvent(t, text, payload))
+}
+
+func newMessageEventContext(t *testing.T, payload map[string]interface{}) *Context {
+	return NewContext(context.Background(), nil, newMessageEventEvent(t, payload))
+}
+
+func TestRouter_Command(t *testing.T) {
+	var args string
+	called := 0
+	r := NewRouter().Command("/start", func(c *Context) error {
+		called++
+		args = c.Args()
+		return nil
+	})
+
+	r.Handle(newMessageNewContext(t, "/start", ""))
+	r.Handle(newMessageNewContext(t, "/start  ref 1 ", ""))
+	if called != 2 || args != "ref 1" {
+		t.Error("should be handled command with args", called, args)
+	}
+	r.Handle(newMessageNewContext(t, "/starting", ""))
+	r.Handle(newMessageNewContext(t, "/START", ""))
+	if called != 2 {
+		t.Error("should not be handled other commands")
+	}
+	r.Handle(newMessageNewContext(t, "[club1|@bot], /start", ""))
+	if called != 3 {
+		t.Error("should be handled command with bot mention")
+	}
+}
+
+func TestRouter_Text(t *testing.T) {
+	called := false
+	r := NewRouter().Text("go", func(_ *Context) error {
+		called = true
+		return nil
+	})
+	r.Handle(newMessageNewContext(t, "go on", ""))
+	if called {
+		t.Error("should not be handled not equal text")
+	}
+	r.Handle(newMessageNewContext(t, " go ", ""))
+	if !called {
+		t.Error("should be handled equal text")
+	}
+}
+
+func TestRouter_Regexp(t *testing.T) {
+	var groups []string
+	var named map[string]string
+	r := NewRouter().Regexp(`^buy (?P<count>\d+) (\w+)$`, func(c *Context) error {
+		groups = c.Groups()
+		named = c.NamedGroups()
+		return nil
+	})
+	r.Handle(newMessageNewContext(t, "buy 3 apples", ""))
+	if !reflect.DeepEqual(groups, []string{"buy 3 apples", "3", "apples"}) {
+		t.Error("should be captured groups", groups)
+	}
+	if named["count"] != "3" {
+		t.Error("should be captured named groups", named)
+	}
+}
+
+func TestRouter_Payload(t *testing.T) {
+	called := 0
+	r := NewRouter().Payload("menu", func(_ *Context) error {
+		called++
+		return nil
+	})
+	r.Handle(newMessageNewContext(t, "Menu", `{"cmd":"menu"}`))
+	r.Handle(newMessageEventContext(t, map[string]interface{}{"cmd": "menu"}))
+	r.Handle(newMessageEventContext(t, map[string]interface{}{"cmd": "other"}))
+	r.Handle(newMessageNewContext(t, "menu", `not json`))
+	if called != 2 {
+		t.Error("should be handled button clicks with payload", called)
+	}
+}
+
+func TestRouter_IgnoreCase(t *testing.T) {
+	called := 0
+	h := func(_ *Context) error {
+		called++
+		return nil
+	}
+	r := NewRouter().IgnoreCase(true).
+		Command("/start", h).
+		Text("hello", h).
+		Regexp(`^bye$`, h).
+		Payload("menu", h)
+	r.Handle(newMessageNewContext(t, "/START now", ""))
+	r.Handle(newMessageNewContext(t, "HeLLo", ""))
+	r.Handle(newMessageNewContext(t, "BYE", ""))
+	r.Handle(newMessageEventContext(t, map[string]interface{}{"cmd": "MENU"}))
+	if called != 4 {
+		t.Error("should be matched case-insensitively", called)
+	}
+}
+
+func TestRouter_OrderAndFallback(t *testing.T) {
+	var handled string
+	r := NewRouter().
+		Text("go", func(_ *Context) error {
+			handled = "text"
+			return nil
+		}).
+		Regexp(`.*`, func(_ *Context) error {
+			handled = "regexp"
+			return nil
+		})
+	r.Handle(newMessageNewContext(t, "go", ""))
+	if handled != "text" {
+		t.Error("should be handled by the first matched route", handled)
+	}
+
+	handled = ""
+	r = NewRouter().Text("go", func(_ *Context) error {
+		handled = "text"
+		return nil
+	})
+	if err := r.Handle(newMessageNewContext(t, "stop", "")); err != nil || handled != "" {
+		t.Error("should be ignored not matched event")
+	}
+	r.Fallback(func(c *Context) error {
+		handled = "fallback"
+		if c.Groups() != nil || c.Args() != "" {
+			t.Error("should not be match details in fallback")
+		}
+		return nil
+	})
+	r.Handle(newMessageNewContext(t, "stop", ""))
+	if handled != "fallback" {
+		t.Error("should be handled by fallback", handled)
+	}
+}
+
+func TestRouter_RoutedEvent(t *testing.T) {
+	r := NewRouter().Text("go", func(c *Context) error {
+		if c.Type() != event.MessageNewType || c.EventID() != "test_event_id" {
+			t.Error("should be original event")
+		}
+		if _, err := c.AsMessageNew(); err != nil {
+			t.Error("should be typed accessors", err)
+		}
+		return nil
+	})
+	r.Handle(newMessageNewContext(t, "go", ""))
+}
@@ -0,0 +1,66 @@
+package vkbot
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+// errReporter reports fatal error which stopped updates loop
+type errReporter interface {
+	Err() error
+}
+
+// Run initializes bot and serves events until ctx is done or updates loop is stopped by fatal error,
+// e.g. revoked token, then it shuts down bot waiting for received events no longer than ShutdownTimeout,
+// it returns nil if ctx is done and bot is shut down gracefully
+func (bot *VkBot) Run(ctx context.Context) error {
+	if err := bot.Init(); err != nil {
+		return err
+	}
+	events, err := bot.start()
+	if err != nil {
+		return err
+	}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		bot.dispatcher.dispatch(events)
+	}()
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+	case <-stopped:
+		runErr = ErrUpdatesLoopStopped
+		if r, ok := bot.longPollServer.(errReporter); ok && r.Err() != nil {
+			runErr = r.Err()
+		}
+	}
+
+	timeout := bot.config.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := bot.Shutdown(shutdownCtx); err != nil {
+		if runErr != nil {
+			Logger.Error("shutdown error", zap.Error(err))
+			return runErr
+		}
+		return err
+	}
+	return runErr
+}
+
+// SignalContext returns copy of parent context cancelled on SIGINT or SIGTERM,
+// stop restores default signals behavior
+func SignalContext(parent context.Context) (ctx context.Context, stop context.CancelFunc) {
+	return signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+}
@@ -0,0 +1,119 @@
+package vkbot
+
+import (
+	"context"
+	"errors"
+	"github.com/karlseguin/typed"
+	"golang.org/x/time/rate"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fatalLongPollServer struct {
+	*fakeLongPollServer
+	err error
+}
+
+func (f *fatalLongPollServer) Err() error {
+	return f.err
+}
+
+func TestVkBot_RunUntilContextDone(t *testing.T) {
+	longPollServer := newFakeLongPollServer()
+	updatesChan := make(chan Update)
+	longPollServer.startUpdatesLoopFunc = func() <-chan Update {
+		return updatesChan
+	}
+	longPollServer.hooksByMethods["StopUpdatesLoop"] = func() {
+		close(updatesChan)
+	}
+	bot := &VkBot{
+		handlers:       map[string]HandleFunc{},
+		longPollServer: longPollServer,
+		config:         BotConfig{Workers: 1},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- bot.Run(ctx)
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error("should not be error", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("timed out")
+	}
+}
+
+func TestVkBot_RunFatalError(t *testing.T) {
+	longPollServer := &fatalLongPollServer{
+		fakeLongPollServer: newFakeLongPollServer(),
+		err:                &APIError{Code: ErrorCodeGroupAuthFailed},
+	}
+	longPollServer.startUpdatesLoopFunc = func() <-chan Update {
+		updatesChan := make(chan Update)
+		close(updatesChan)
+		return updatesChan
+	}
+	bot := &VkBot{
+		handlers:       map[string]HandleFunc{},
+		longPollServer: longPollServer,
+		config:         BotConfig{Workers: 1},
+	}
+	err := bot.Run(context.Background())
+	if !IsErrorCode(err, ErrorCodeGroupAuthFailed) {
+		t.Error("should be fatal error of long poll", err)
+	}
+
+	longPollServer.err = nil
+	if err := bot.Run(context.Background()); !errors.Is(err, ErrUpdatesLoopStopped) {
+		t.Error("should be updates loop stopped error", err)
+	}
+}
+
+type authFailedVkAPI struct {
+	fakeVkAPI
+}
+
+func (api *authFailedVkAPI) CallMethodContext(_ context.Context, methodName string, _ Params) (typed.Typed, error) {
+	return nil, &APIError{Method: methodName, Code: ErrorCodeAuthFailed, Message: "invalid access_token"}
+}
+
+func TestGroupLongPollServer_FatalError(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"failed": 2}`))
+		}))
+	defer server.Close()
+
+	s := &groupLongPollServer{
+		VkAPI:      &authFailedVkAPI{},
+		config:     LongPollConfig{Limiter: rate.NewLimiter(rate.Inf, 0)},
+		client:     server.Client(),
+		Server:     server.URL,
+		mtx:        &sync.Mutex{},
+		hookDealer: defaultHookDealer(),
+		eventCtx:   context.Background(),
+	}
+	updates := s.StartUpdatesLoop()
+	defer s.StopUpdatesLoop()
+	select {
+	case _, ok := <-updates:
+		if ok {
+			t.Error("should not be updates")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	if !IsErrorCode(s.Err(), ErrorCodeAuthFailed) {
+		t.Error("should be fatal error", s.Err())
+	}
+}
@@ -0,0 +1,89 @@
+package vkbot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ShutdownError returned by Shutdown if context is done before all events are handled
+type ShutdownError struct {
+	// Abandoned number of received events which were not handled
+	Abandoned int
+	Err       error
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("shutdown: %d events abandoned: %s", e.Abandoned, e.Err)
+}
+
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
+
+// inFlight tracks events received from long poll server until they are handled
+type inFlight struct {
+	wg    sync.WaitGroup
+	count int64
+	// fed closed when no more events are received
+	fed chan struct{}
+}
+
+func newInFlight() *inFlight {
+	return &inFlight{fed: make(chan struct{})}
+}
+
+func (f *inFlight) add() {
+	f.wg.Add(1)
+	atomic.AddInt64(&f.count, 1)
+}
+
+func (f *inFlight) done() {
+	atomic.AddInt64(&f.count, -1)
+	f.wg.Done()
+}
+
+func (f *inFlight) pending() int {
+	return int(atomic.LoadInt64(&f.count))
+}
+
+// wait waits until all received events are handled
+func (f *inFlight) wait(ctx context.Context) error {
+	select {
+	case <-f.fed:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	drained := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown stops receiving events and waits until events already received are handled,
+// then stops workers, if ctx is done earlier it returns *ShutdownError with number of abandoned events,
+// repeated Shutdown or Stop after it do nothing
+func (bot *VkBot) Shutdown(ctx context.Context) error {
+	d := bot.loadDispatcher()
+	if d == nil || bot.inFlight == nil {
+		return fmt.Errorf("shutdown: bot is not started")
+	}
+	bot.longPollServer.StopUpdatesLoop()
+	err := bot.inFlight.wait(ctx)
+	// handlers still running are cancelled
+	bot.cancel()
+	d.stopWorkers(func() { /*dumb hook*/ })
+	if err != nil {
+		return &ShutdownError{Abandoned: bot.inFlight.pending(), Err: err}
+	}
+	Logger.Info("VkBot stopped gracefully")
+	return nil
+}
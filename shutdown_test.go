@@ -0,0 +1,130 @@
+package vkbot
+
+import (
+	"context"
+	"errors"
+	"github.com/AndrewShukhtin/vkbot/event"
+	"github.com/karlseguin/typed"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestBotWithEvents(t *testing.T, n int) (*VkBot, chan Update) {
+	events := make([]typed.Typed, 0, n)
+	for i := 0; i < n; i++ {
+		events = append(events, newTestEvent(t, event.MessageNewType, typed.Typed{}, 0, "xoox").Data())
+	}
+	u, err := NewUpdate(typed.Typed{"ts": "0", "updates": events})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updatesChan := make(chan Update, 1)
+	updatesChan <- u
+	longPollServer := newFakeLongPollServer()
+	longPollServer.startUpdatesLoopFunc = func() <-chan Update {
+		return updatesChan
+	}
+	var stopOnce sync.Once
+	longPollServer.hooksByMethods["StopUpdatesLoop"] = func() {
+		stopOnce.Do(func() { close(updatesChan) })
+	}
+	bot := &VkBot{
+		handlers:       map[string]HandleFunc{},
+		longPollServer: longPollServer,
+		config:         BotConfig{Workers: 2, WorkerBuffer: 1, Events: n},
+	}
+	return bot, updatesChan
+}
+
+func TestVkBot_ShutdownDrainsEvents(t *testing.T) {
+	bot, _ := newTestBotWithEvents(t, 5)
+	started := make(chan struct{}, 5)
+	var handled int64
+	bot.EventHandler(event.MessageNewType, func(_ *Context) error {
+		started <- struct{}{}
+		time.Sleep(time.Millisecond * 20)
+		atomic.AddInt64(&handled, 1)
+		return nil
+	})
+	go bot.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := bot.Shutdown(ctx); err != nil {
+		t.Error("should not be error", err)
+	}
+	if n := atomic.LoadInt64(&handled); n != 5 {
+		t.Error("should be handled all received events", n)
+	}
+}
+
+func TestVkBot_ShutdownAbandonsEvents(t *testing.T) {
+	bot, _ := newTestBotWithEvents(t, 3)
+	started := make(chan struct{}, 3)
+	release := make(chan struct{})
+	defer close(release)
+	bot.EventHandler(event.MessageNewType, func(_ *Context) error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	go bot.Start()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	err := bot.Shutdown(ctx)
+	var shutdownErr *ShutdownError
+	if !errors.As(err, &shutdownErr) {
+		t.Fatal("should be ShutdownError", err)
+	}
+	if shutdownErr.Abandoned != 3 {
+		t.Error("should be abandoned all not handled events", shutdownErr.Abandoned)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("should wrap context error")
+	}
+}
+
+func TestVkBot_ShutdownNotStarted(t *testing.T) {
+	bot := &VkBot{}
+	if err := bot.Shutdown(context.Background()); err == nil {
+		t.Error("should be error")
+	}
+}
+
+func TestVkBot_StopTwice(t *testing.T) {
+	bot, _ := newTestBotWithEvents(t, 1)
+	handled := make(chan struct{}, 1)
+	bot.EventHandler(event.MessageNewType, func(_ *Context) error {
+		handled <- struct{}{}
+		return nil
+	})
+	go bot.Start()
+	<-handled
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := bot.Shutdown(ctx); err != nil {
+		t.Error("should not be error", err)
+	}
+	if err := bot.Shutdown(ctx); err != nil {
+		t.Error("repeated shutdown should not be error", err)
+	}
+	bot.Stop()
+	done := bot.dispatcher.done
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("should be stopped workers")
+	}
+}
+
+func TestVkBot_StopNotStarted(t *testing.T) {
+	bot := &VkBot{}
+	bot.Stop()
+}
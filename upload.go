@@ -0,0 +1,174 @@
+package vkbot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/karlseguin/typed"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+)
+
+// Types of documents uploaded with docs.getMessagesUploadServer
+const (
+	DocUploadType          = "doc"
+	AudioMessageUploadType = "audio_message"
+	GraffitiUploadType     = "graffiti"
+)
+
+// Uploader uploads files to be sent as messages attachments
+type Uploader struct {
+	api    VkAPI
+	client *http.Client
+}
+
+// NewUploader creates Uploader on top of VkAPI
+func NewUploader(api VkAPI) *Uploader {
+	return &Uploader{api: api, client: client}
+}
+
+type uploadServer struct {
+	UploadURL string `json:"upload_url"`
+}
+
+type savedObject struct {
+	ID        int    `json:"id"`
+	OwnerID   int    `json:"owner_id"`
+	AccessKey string `json:"access_key"`
+}
+
+func (o savedObject) attachment(kind string) string {
+	if o.AccessKey == "" {
+		return fmt.Sprintf("%s%d_%d", kind, o.OwnerID, o.ID)
+	}
+	return fmt.Sprintf("%s%d_%d_%s", kind, o.OwnerID, o.ID, o.AccessKey)
+}
+
+type savedDoc struct {
+	Type         string       `json:"type"`
+	Doc          *savedObject `json:"doc"`
+	AudioMessage *savedObject `json:"audio_message"`
+	Graffiti     *savedObject `json:"graffiti"`
+}
+
+// MessagePhoto uploads photo for message to peerID and returns attachment string
+func (u *Uploader) MessagePhoto(ctx context.Context, peerID int, r io.Reader, filename string) (string, error) {
+	server, err := Call[uploadServer](ctx, u.api, "photos.getMessagesUploadServer", Params{"peer_id": peerID})
+	if err != nil {
+		return "", err
+	}
+	uploaded, err := u.upload(ctx, server.UploadURL, "photo", r, filename)
+	if err != nil {
+		return "", err
+	}
+	if p := uploaded.String("photo"); p == "" || p == "[]" {
+		err := newInternalError(fmt.Errorf("photo not uploaded"), "upload of %s", filename)
+		err.Misc["resp"] = uploaded
+		return "", err
+	}
+	photos, err := Call[[]savedObject](ctx, u.api, "photos.saveMessagesPhoto", Params{
+		"photo":  uploaded.String("photo"),
+		"server": uploaded.Int("server"),
+		"hash":   uploaded.String("hash"),
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(photos) == 0 {
+		return "", newInternalError(fmt.Errorf("no saved photos"), "method called photos.saveMessagesPhoto")
+	}
+	return photos[0].attachment("photo"), nil
+}
+
+// Document uploads document for message to peerID and returns attachment string
+func (u *Uploader) Document(ctx context.Context, peerID int, r io.Reader, filename string) (string, error) {
+	return u.uploadDoc(ctx, DocUploadType, peerID, r, filename)
+}
+
+// AudioMessage uploads voice message for peerID and returns attachment string
+func (u *Uploader) AudioMessage(ctx context.Context, peerID int, r io.Reader, filename string) (string, error) {
+	return u.uploadDoc(ctx, AudioMessageUploadType, peerID, r, filename)
+}
+
+// Graffiti uploads graffiti for message to peerID and returns attachment string
+func (u *Uploader) Graffiti(ctx context.Context, peerID int, r io.Reader, filename string) (string, error) {
+	return u.uploadDoc(ctx, GraffitiUploadType, peerID, r, filename)
+}
+
+func (u *Uploader) uploadDoc(ctx context.Context, docType string, peerID int, r io.Reader, filename string) (string, error) {
+	server, err := Call[uploadServer](ctx, u.api, "docs.getMessagesUploadServer", Params{
+		"type":    docType,
+		"peer_id": peerID,
+	})
+	if err != nil {
+		return "", err
+	}
+	uploaded, err := u.upload(ctx, server.UploadURL, "file", r, filename)
+	if err != nil {
+		return "", err
+	}
+	if uploaded.String("file") == "" {
+		err := newInternalError(fmt.Errorf("document not uploaded"), "upload of %s", filename)
+		err.Misc["resp"] = uploaded
+		return "", err
+	}
+	doc, err := Call[savedDoc](ctx, u.api, "docs.save", Params{
+		"file":  uploaded.String("file"),
+		"title": filename,
+	})
+	if err != nil {
+		return "", err
+	}
+	// voice messages and graffiti are sent as documents
+	for _, o := range []*savedObject{doc.Doc, doc.AudioMessage, doc.Graffiti} {
+		if o != nil {
+			return o.attachment("doc"), nil
+		}
+	}
+	return "", newInternalError(fmt.Errorf("unknown saved document type '%s'", doc.Type), "method called docs.save")
+}
+
+// upload posts file as multipart form field to upload server
+func (u *Uploader) upload(ctx context.Context, uploadURL string, field string, r io.Reader, filename string) (typed.Typed, error) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	part, err := w.CreateFormFile(field, filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return nil, newInternalError(err, "can not read %s", filename)
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, body)
+	if err != nil {
+		return nil, newInternalError(err, "invalid upload request")
+	}
+	httpReq.Header.Set("Content-Type", w.FormDataContentType())
+
+	httpResp, err := u.client.Do(httpReq)
+	if err != nil {
+		return nil, newInternalError(err, "error occurred while uploading %s", filename)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, newInternalError(err, "error occurred while reading upload response")
+	}
+	reply, err := typed.Json(respBody)
+	if err != nil {
+		return nil, newInternalError(err, "error occurred while unmarshalling upload response")
+	}
+	if _, ok := reply["error"]; ok {
+		err := newInternalError(fmt.Errorf("upload server error"), "upload of %s", filename)
+		err.Misc["resp"] = reply
+		return nil, err
+	}
+	return reply, nil
+}
@@ -0,0 +1,102 @@
+package vkbot
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newUploadServer(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/method/photos.getMessagesUploadServer", "/method/docs.getMessagesUploadServer":
+				w.Write([]byte(`{"response": {"upload_url": "` + server.URL + `/upload/` + r.FormValue("type") + `"}}`))
+			case "/upload/", "/upload/doc", "/upload/audio_message", "/upload/graffiti":
+				field := "file"
+				if r.URL.Path == "/upload/" {
+					field = "photo"
+				}
+				f, h, err := r.FormFile(field)
+				if err != nil {
+					t.Error("file should be uploaded", err)
+					w.Write([]byte(`{"error": "no file"}`))
+					return
+				}
+				data, _ := ioutil.ReadAll(f)
+				if string(data) != "content" || h.Filename != "test.ogg" {
+					t.Error("invalid uploaded file", string(data), h.Filename)
+				}
+				if field == "photo" {
+					w.Write([]byte(`{"server": 1, "photo": "[{\"photo\":\"abc\"}]", "hash": "hash"}`))
+					return
+				}
+				w.Write([]byte(`{"file": "` + strings.TrimPrefix(r.URL.Path, "/upload/") + `"}`))
+			case "/method/photos.saveMessagesPhoto":
+				if r.FormValue("hash") != "hash" || r.FormValue("server") != "1" {
+					t.Error("invalid save params", r.Form)
+				}
+				w.Write([]byte(`{"response": [{"id": 2, "owner_id": -3, "access_key": "key"}]}`))
+			case "/method/docs.save":
+				docType := r.FormValue("file")
+				w.Write([]byte(`{"response": {"type": "` + docType + `", "` + docType + `": {"id": 4, "owner_id": 5}}}`))
+			default:
+				w.Write([]byte(`{"error": {"error_code": 3, "error_msg": "Unknown method passed"}}`))
+			}
+		}))
+	return server
+}
+
+func TestUploader(t *testing.T) {
+	server := newUploadServer(t)
+	defer server.Close()
+
+	api := &vkAPI{URL: server.URL + "/method/", client: server.Client()}
+	u := NewUploader(api)
+	u.client = server.Client()
+
+	photo, err := u.MessagePhoto(context.Background(), 1, strings.NewReader("content"), "test.ogg")
+	if err != nil || photo != "photo-3_2_key" {
+		t.Error("invalid photo attachment", photo, err)
+	}
+
+	uploads := map[string]func(context.Context, int, io.Reader, string) (string, error){
+		"doc":           u.Document,
+		"audio_message": u.AudioMessage,
+		"graffiti":      u.Graffiti,
+	}
+	for name, upload := range uploads {
+		doc, err := upload(context.Background(), 1, strings.NewReader("content"), "test.ogg")
+		if err != nil || doc != "doc5_4" {
+			t.Errorf("%s: invalid attachment %s, error %v", name, doc, err)
+		}
+	}
+}
+
+func TestUploaderUploadError(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/method/") {
+				w.Write([]byte(`{"response": {"upload_url": "` + "http://" + r.Host + `/upload"}}`))
+				return
+			}
+			w.Write([]byte(`{"error": "file too big"}`))
+		}))
+	defer server.Close()
+
+	api := &vkAPI{URL: server.URL + "/method/", client: server.Client()}
+	u := NewUploader(api)
+	u.client = server.Client()
+
+	if _, err := u.Document(context.Background(), 1, strings.NewReader("content"), "test.txt"); err == nil {
+		t.Error("should be error when upload server fails")
+	}
+	if _, err := u.MessagePhoto(context.Background(), 1, strings.NewReader("content"), "test.png"); err == nil {
+		t.Error("should be error when upload server fails")
+	}
+}
@@ -1,33 +1,139 @@
 package vkbot
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"go.uber.org/zap"
+	"math/rand"
 	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
 // Params allows you to pass keys with values of various types
-// It support only int and string
+// It supports strings, numbers, bools, time.Time, json.Marshaler, fmt.Stringer,
+// structs (encoded to json) and slices of them (encoded as comma-separated list)
 type Params map[string]interface{}
 
 // URLValues convert Params to url.Values
-// Do not skipped only string or numeric fields
+// Values of unsupported types are skipped with warning
+//
+// Deprecated: use Values, it returns an error instead of skipping values
 func (p Params) URLValues() url.Values {
 	values := url.Values{}
 	for name, i := range p {
-		switch i.(type) {
-		case string, int, int8, int16, int32, int64,
-			uint, uint8, uint16, uint32, uint64,
-			float32, float64:
-		default:
+		if i == nil {
 			continue
 		}
-		values.Set(name, fmt.Sprintf("%v", i))
+		s, err := encodeParam(i)
+		if err != nil {
+			Logger.Warn(fmt.Sprintf("param '%s' skipped", name), zap.Error(err))
+			continue
+		}
+		values.Set(name, s)
 	}
 	return values
 }
 
+// Values convert Params to url.Values
+// Returns error if any value has unsupported type, nil values are skipped
+func (p Params) Values() (url.Values, error) {
+	values := url.Values{}
+	for name, i := range p {
+		if i == nil {
+			continue
+		}
+		s, err := encodeParam(i)
+		if err != nil {
+			return nil, fmt.Errorf("param '%s': %w", name, err)
+		}
+		values.Set(name, s)
+	}
+	return values, nil
+}
+
+// isZero reports whether v is zero value of its type
+func isZero(v interface{}) bool {
+	return reflect.ValueOf(v).IsZero()
+}
+
+// copy returns shallow copy of params
+func (p Params) copy() Params {
+	res := make(Params, len(p))
+	for name, v := range p {
+		res[name] = v
+	}
+	return res
+}
+
+func encodeParam(i interface{}) (string, error) {
+	if rv := reflect.ValueOf(i); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		// methods of Marshaler or Stringer may panic on nil receiver
+		return "", fmt.Errorf("nil %T is not supported", i)
+	}
+	switch v := i.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case time.Time:
+		return strconv.FormatInt(v.Unix(), 10), nil
+	case *time.Time:
+		// *time.Time implements json.Marshaler, but it is sent as unix time too
+		return encodeParam(*v)
+	case []byte:
+		return string(v), nil
+	case json.Marshaler:
+		data, err := v.MarshalJSON()
+		return string(data), err
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+
+	rv := reflect.ValueOf(i)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return encodeParam(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return fmt.Sprintf("%v", i), nil
+	case reflect.Slice, reflect.Array:
+		items := make([]string, rv.Len())
+		for j := 0; j < rv.Len(); j++ {
+			item := rv.Index(j)
+			if k := item.Kind(); k == reflect.Slice || k == reflect.Array || k == reflect.Map {
+				return "", fmt.Errorf("nested %v is not supported", item.Type())
+			}
+			s, err := encodeParam(item.Interface())
+			if err != nil {
+				return "", err
+			}
+			items[j] = s
+		}
+		return strings.Join(items, ","), nil
+	case reflect.Struct:
+		data, err := json.Marshal(i)
+		return string(data), err
+	case reflect.Ptr:
+		if rv.Elem().Kind() == reflect.Struct {
+			data, err := json.Marshal(i)
+			return string(data), err
+		}
+		return encodeParam(rv.Elem().Interface())
+	}
+	return "", fmt.Errorf("unsupported type %T", i)
+}
+
 type overHeater struct {
 	threshold      time.Duration
 	counter        int
@@ -72,3 +178,35 @@ func (o *overHeater) isOverHeated() bool {
 	}
 	return false
 }
+
+type backoff struct {
+	min    time.Duration
+	max    time.Duration
+	jitter float64
+}
+
+// duration returns exponentially growing delay before retry number attempt (starting from 0)
+func (b backoff) duration(attempt int) time.Duration {
+	d := b.max
+	if attempt < 32 {
+		if exp := b.min << uint(attempt); exp > 0 && exp < b.max {
+			d = exp
+		}
+	}
+	if b.jitter > 0 {
+		delta := float64(d) * b.jitter
+		d = time.Duration(float64(d) - delta + rand.Float64()*2*delta)
+	}
+	return d
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -1,6 +1,8 @@
 package vkbot
 
 import (
+	"context"
+	"fmt"
 	"net/url"
 	"sync"
 	"testing"
@@ -22,6 +24,99 @@ func TestParams(t *testing.T) {
 	}
 }
 
+type testStringer int
+
+func (s testStringer) String() string {
+	return fmt.Sprintf("stringer-%d", int(s))
+}
+
+type testMarshaler struct{}
+
+func (testMarshaler) MarshalJSON() ([]byte, error) {
+	return []byte(`{"marshaled":true}`), nil
+}
+
+type testPtrStringer struct {
+	name string
+}
+
+func (s *testPtrStringer) String() string {
+	return s.name
+}
+
+type testStruct struct {
+	Field string `json:"field"`
+}
+
+func TestParamsValues(t *testing.T) {
+	ts := time.Unix(1617000000, 0)
+	params := Params{
+		"string":    "test",
+		"int":       -1,
+		"uint8":     uint8(2),
+		"float":     1.5,
+		"true":      true,
+		"false":     false,
+		"ints":      []int{1, 2, 3},
+		"strings":   [2]string{"a", "b"},
+		"bools":     []bool{true, false},
+		"time":      ts,
+		"time_ptr":  &ts,
+		"stringer":  testStringer(7),
+		"marshaler": testMarshaler{},
+		"struct":    testStruct{Field: "value"},
+		"ptr":       &testStruct{Field: "ptr"},
+		"nil":       nil,
+	}
+	expected := map[string]string{
+		"string":    "test",
+		"int":       "-1",
+		"uint8":     "2",
+		"float":     "1.5",
+		"true":      "1",
+		"false":     "0",
+		"ints":      "1,2,3",
+		"strings":   "a,b",
+		"bools":     "1,0",
+		"time":      "1617000000",
+		"time_ptr":  "1617000000",
+		"stringer":  "stringer-7",
+		"marshaler": `{"marshaled":true}`,
+		"struct":    `{"field":"value"}`,
+		"ptr":       `{"field":"ptr"}`,
+	}
+
+	values, err := params.Values()
+	if err != nil {
+		t.Fatal("should not be error", err)
+	}
+	if len(values) != len(expected) {
+		t.Errorf("expected %d values, got %d: %v", len(expected), len(values), values)
+	}
+	for k, v := range expected {
+		if values.Get(k) != v {
+			t.Errorf("param %s: expected %s, got %s", k, v, values.Get(k))
+		}
+	}
+}
+
+func TestParamsValuesUnsupported(t *testing.T) {
+	unsupported := []Params{
+		{"map": map[string]int{"a": 1}},
+		{"params": Params{"test": 1}},
+		{"nested": [][]int{{1}}},
+		{"chan": make(chan int)},
+		{"nil_ptr": (*testStruct)(nil)},
+		{"nil_stringer": (*testPtrStringer)(nil)},
+		{"nil_time": (*time.Time)(nil)},
+	}
+	for _, p := range unsupported {
+		if _, err := p.Values(); err == nil {
+			t.Error("should be error for unsupported type", p)
+		}
+	}
+}
+
 func TestOverHeaterWithoutHeat(t *testing.T) {
 	o := newOverHeater(time.Second, 3)
 	for i := 0; i < 2; i++ {
@@ -68,3 +163,41 @@ func TestOverHeaterConcurrent(t *testing.T) {
 		t.Error("should be overheated")
 	}
 }
+
+func TestBackoff(t *testing.T) {
+	b := backoff{min: 10 * time.Millisecond, max: 100 * time.Millisecond}
+	expected := []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		40 * time.Millisecond,
+		80 * time.Millisecond,
+		100 * time.Millisecond,
+		100 * time.Millisecond,
+	}
+	for i, e := range expected {
+		if d := b.duration(i); d != e {
+			t.Errorf("attempt %d: expected %v, got %v", i, e, d)
+		}
+	}
+	if d := b.duration(100); d != b.max {
+		t.Errorf("should not overflow, got %v", d)
+	}
+
+	b.jitter = 0.5
+	for i := 0; i < 10; i++ {
+		if d := b.duration(0); d < 5*time.Millisecond || d > 15*time.Millisecond {
+			t.Errorf("jittered delay out of range: %v", d)
+		}
+	}
+}
+
+func TestSleepContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sleepContext(ctx, time.Hour); err != context.Canceled {
+		t.Error("should be canceled", err)
+	}
+	if err := sleepContext(context.Background(), time.Millisecond); err != nil {
+		t.Error("should not be error", err)
+	}
+}
@@ -1,17 +1,41 @@
 package vkbot
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/karlseguin/typed"
+	"go.uber.org/zap"
 	"io/ioutil"
 	"net/http"
+	"strings"
 )
 
 // VkAPI wraps vk api methods to call
 type VkAPI interface {
 	// CallMethod calls api.vk.com method by name with params
 	CallMethod(methodName string, params Params) (typed.Typed, error)
+
+	// CallMethodContext calls api.vk.com method by name with params,
+	// request is cancelled when ctx is done
+	CallMethodContext(ctx context.Context, methodName string, params Params) (typed.Typed, error)
+
+	// SetConfig set VkAPI configuration
+	SetConfig(config VkAPIConfig)
+}
+
+// VkAPIConfig enable to configure VkAPI
+type VkAPIConfig struct {
+	// Retry policy of retrying transient errors
+	Retry RetryPolicy
+
+	// RateLimit limits outgoing requests per token,
+	// zero Rate means GroupTokenRate or UserTokenRate depending on constructor of VkAPI
+	RateLimit RateLimit
+
+	// CaptchaSolver solves captcha to repeat the call, fails fast by default
+	CaptchaSolver CaptchaSolver
 }
 
 type vkAPI struct {
@@ -20,21 +44,54 @@ type vkAPI struct {
 	URL      string
 	Token    string
 
-	client *http.Client
+	client    *http.Client
+	config    VkAPIConfig
+	limiter   *tokenLimiter
+	userToken bool
 }
 
-// NewVkAPI create new vk api with token
+// NewVkAPI create new vk api with group token
 // and default version
 func NewVkAPI(token string) VkAPI {
+	return newVkAPI(token, false)
+}
+
+// NewUserVkAPI create new vk api with user token
+// and default version, requests are limited by UserTokenRate
+func NewUserVkAPI(token string) VkAPI {
+	return newVkAPI(token, true)
+}
+
+func newVkAPI(token string, userToken bool) *vkAPI {
 	vkAPI := &vkAPI{
-		Version: VkAPIVersion,
-		URL:     VkAPIUrl,
-		Token:   token,
-		client:  client,
+		Version:   VkAPIVersion,
+		URL:       VkAPIUrl,
+		Token:     token,
+		client:    client,
+		config:    defaultVkAPIConfig(),
+		userToken: userToken,
 	}
+	vkAPI.config.RateLimit = defaultRateLimit(userToken)
+	vkAPI.limiter = newTokenLimiter(vkAPI.config.RateLimit)
 	return vkAPI
 }
 
+// SetConfig sets not zero sections of config, zero sections keep current configuration
+func (api *vkAPI) SetConfig(config VkAPIConfig) {
+	if !isZero(config.Retry) || isZero(api.config.Retry) {
+		api.config.Retry = config.Retry.withDefaults()
+	}
+	if !isZero(config.RateLimit) || api.limiter == nil {
+		api.config.RateLimit = config.RateLimit.withDefaults(api.userToken)
+		api.limiter = newTokenLimiter(api.config.RateLimit)
+	}
+	if config.CaptchaSolver != nil {
+		api.config.CaptchaSolver = config.CaptchaSolver
+	} else if api.config.CaptchaSolver == nil {
+		api.config.CaptchaSolver = NewFailFastCaptchaSolver()
+	}
+}
+
 func (api *vkAPI) SetLanguage(lang string) {
 	api.Language = lang
 }
@@ -44,12 +101,116 @@ func (api *vkAPI) SetToken(token string) {
 }
 
 func (api *vkAPI) CallMethod(methodName string, params Params) (typed.Typed, error) {
+	return api.CallMethodContext(context.Background(), methodName, params)
+}
+
+func (api *vkAPI) CallMethodContext(ctx context.Context, methodName string, params Params) (typed.Typed, error) {
+	resp, err := api.call(ctx, methodName, params)
+	if err != nil {
+		return nil, err
+	}
+	return unwrapResponse(methodName, resp.data)
+}
+
+func (api *vkAPI) callRaw(ctx context.Context, methodName string, params Params) (json.RawMessage, error) {
+	resp, err := api.call(ctx, methodName, params)
+	if err != nil {
+		return nil, err
+	}
+	return resp.raw, nil
+}
+
+// apiResponse successful response of vk api
+type apiResponse struct {
+	// data whole response body
+	data typed.Typed
+
+	// raw not decoded 'response' field
+	raw json.RawMessage
+}
+
+// call calls method retrying transient errors according to retry policy
+func (api *vkAPI) call(ctx context.Context, methodName string, params Params) (*apiResponse, error) {
+	policy := api.config.Retry
+	retryable := policy.allows(methodName, params)
+	captchas := 0
+	// captcha answers are added to copy of params, so params of caller are kept as is
+	params = params.copy()
+	for attempt := 1; ; attempt++ {
+		if api.limiter != nil {
+			if err := api.limiter.wait(ctx, api.Token, methodName); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := api.callOnce(ctx, methodName, params)
+		if IsErrorCode(err, ErrorCodeCaptchaNeeded) && captchas < maxCaptchaAttempts {
+			captchas++
+			if !api.solveCaptcha(ctx, params, err) {
+				return nil, err
+			}
+			// captcha attempts are not counted as retries
+			attempt--
+			continue
+		}
+		// request error of cancelled ctx is not transient, so it is returned without retry warning
+		if err == nil || ctx.Err() != nil || !retryable || attempt >= policy.MaxAttempts || !policy.isRetryable(err) {
+			return resp, err
+		}
+		delay := policy.backoff(attempt - 1)
+		Logger.Warn(fmt.Sprintf("retrying %s in %v", methodName, delay),
+			zap.Int("attempt", attempt),
+			zap.Error(err))
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (api *vkAPI) retryable(methodName string, params Params, err error) bool {
+	policy := api.config.Retry
+	return policy.allows(methodName, params) && policy.isRetryable(err)
+}
+
+// solveCaptcha adds captcha_sid and captcha_key to params if captcha solved
+func (api *vkAPI) solveCaptcha(ctx context.Context, params Params, err error) bool {
+	solver := api.config.CaptchaSolver
+	if solver == nil {
+		return false
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	key, solveErr := solver.Solve(ctx, apiErr.CaptchaSID, apiErr.CaptchaImg)
+	if solveErr != nil {
+		Logger.Warn("captcha not solved",
+			zap.String("method", apiErr.Method),
+			zap.String("captcha_img", apiErr.CaptchaImg),
+			zap.Error(solveErr))
+		return false
+	}
+	params["captcha_sid"] = apiErr.CaptchaSID
+	params["captcha_key"] = key
+	return true
+}
+
+func (api *vkAPI) callOnce(ctx context.Context, methodName string, params Params) (*apiResponse, error) {
 	params["v"] = api.Version
 	params["lang"] = api.Language
 	params["access_token"] = api.Token
 
-	values := params.URLValues()
-	httpResp, err := api.client.PostForm(api.URL+methodName, values)
+	values, err := params.Values()
+	if err != nil {
+		return nil, newInternalError(err, "invalid params of method %s", methodName)
+	}
+	reqBody := strings.NewReader(values.Encode())
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, api.URL+methodName, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	httpResp, err := api.client.Do(httpReq)
 	if err != nil {
 		return nil, err
 	}
@@ -65,6 +226,9 @@ func (api *vkAPI) CallMethod(methodName string, params Params) (typed.Typed, err
 	if err != nil {
 		return nil, err
 	}
+	if e, ok := data.ObjectIf("error"); ok {
+		return nil, newAPIError(methodName, e)
+	}
 	if _, ok := data["error"]; ok {
 		err := newInternalError(fmt.Errorf("vk api error response"), "method called %s", methodName)
 		err.Misc["resp"] = data
@@ -75,14 +239,34 @@ func (api *vkAPI) CallMethod(methodName string, params Params) (typed.Typed, err
 		err.Misc["resp"] = data
 		return nil, err
 	}
-	if _, ok := data.IntIf("response"); ok {
+
+	raw := struct {
+		Response json.RawMessage `json:"response"`
+	}{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+	return &apiResponse{data: data, raw: raw.Response}, nil
+}
+
+// unwrapResponse returns object response as is,
+// array and scalar (number, string, bool) responses are returned with 'response' field
+func unwrapResponse(methodName string, data typed.Typed) (typed.Typed, error) {
+	if resp, ok := data.ObjectIf("response"); ok {
+		return resp, nil
+	}
+	if data["response"] != nil {
 		return data, nil
 	}
-	resp, ok := data.ObjectIf("response")
-	if !ok {
-		err := newInternalError(fmt.Errorf("response field not a json object"), "method called %s", methodName)
-		err.Misc["response"] = data
-		return nil, err
+	err := newInternalError(fmt.Errorf("response field is missing"), "method called %s", methodName)
+	err.Misc["response"] = data
+	return nil, err
+}
+
+func defaultVkAPIConfig() VkAPIConfig {
+	return VkAPIConfig{
+		Retry:         defaultRetryPolicy(),
+		RateLimit:     defaultRateLimit(false),
+		CaptchaSolver: NewFailFastCaptchaSolver(),
 	}
-	return resp, nil
 }
@@ -1,11 +1,15 @@
 package vkbot
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestInvalidPostForm(t *testing.T) {
@@ -61,6 +65,44 @@ func TestVkApiErrorResponse(t *testing.T) {
 	}
 }
 
+func TestVkApiTypedErrorResponse(t *testing.T) {
+	vkAPIErrorResponse := []byte(
+		`{"error": {"error_code": 6, "error_msg": "Too many requests per second",
+		"request_params": [{"key": "method", "value": "test.Tests"}]}}`,
+	)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(vkAPIErrorResponse)
+		}))
+
+	api := vkAPI{
+		URL:    server.URL + "/",
+		client: server.Client(),
+	}
+	defer server.Close()
+
+	_, err := api.CallMethod("test.Tests", Params{"test": "test"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("should be *APIError, got %v", err)
+	}
+	if apiErr.Code != ErrorCodeTooManyRequests || apiErr.Method != "test.Tests" {
+		t.Errorf("invalid error fields: %+v", apiErr)
+	}
+	if len(apiErr.RequestParams) != 1 || apiErr.RequestParams[0].Value != "test.Tests" {
+		t.Errorf("invalid request params: %+v", apiErr.RequestParams)
+	}
+	if !errors.Is(err, &APIError{Code: ErrorCodeTooManyRequests}) {
+		t.Error("should match error code with errors.Is")
+	}
+	if errors.Is(err, &APIError{Code: ErrorCodeAccessDenied}) {
+		t.Error("should not match another error code")
+	}
+	if !IsErrorCode(fmt.Errorf("wrapped: %w", err), ErrorCodeFloodControl, ErrorCodeTooManyRequests) {
+		t.Error("should find error code in wrapped error")
+	}
+}
+
 func TestVkApiNoResponseField(t *testing.T) {
 	vkAPIResponse := []byte(
 		`{"field": "dump_field"}`,
@@ -82,9 +124,9 @@ func TestVkApiNoResponseField(t *testing.T) {
 	}
 }
 
-func TestVkApiNotJsonObjectResponseField(t *testing.T) {
+func TestVkApiMissingResponseField(t *testing.T) {
 	vkAPIResponse := []byte(
-		`{"response": "dump_field"}`,
+		`{"dump_field": "dump_field"}`,
 	)
 	server := httptest.NewServer(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -158,3 +200,73 @@ func TestVkApiResponse(t *testing.T) {
 		t.Errorf("resp: %v\n vkAPIResponse: %v", resp, i)
 	}
 }
+
+func TestVkApiCallMethodContextCancellation(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+			w.Write([]byte(`{"response": 1}`))
+		}))
+	defer server.Close()
+	defer close(release)
+
+	api := vkAPI{
+		URL:    server.URL + "/",
+		client: server.Client(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := api.CallMethodContext(ctx, "test.Tests", Params{"test": "test"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("should be deadline exceeded", err)
+	}
+}
+
+func TestVkApiArrayResponse(t *testing.T) {
+	vkAPIResponse := []byte(
+		`{"response": [{"peer_id": 1, "message_id": 2}]}`,
+	)
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(vkAPIResponse)
+		}))
+
+	api := vkAPI{
+		URL:    server.URL + "/",
+		client: server.Client(),
+	}
+	defer server.Close()
+
+	resp, err := api.CallMethod("test.Tests", Params{"test": "test"})
+	if err != nil {
+		t.Fatal("should not be error while making request", err)
+	}
+	if rs := resp.Objects("response"); len(rs) != 1 || rs[0].Int("message_id") != 2 {
+		t.Errorf("array should be returned in response field: %v", resp)
+	}
+}
+
+func TestVkApiUnsupportedParam(t *testing.T) {
+	requested := false
+	server := httptest.NewServer(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested = true
+			w.Write([]byte(`{"response": 1}`))
+		}))
+	defer server.Close()
+
+	api := vkAPI{
+		URL:    server.URL + "/",
+		client: server.Client(),
+	}
+
+	_, err := api.CallMethod("test.Tests", Params{"test": map[string]int{}})
+	if err == nil {
+		t.Error("should be error for unsupported param")
+	}
+	if requested {
+		t.Error("request should not be sent")
+	}
+}
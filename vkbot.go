@@ -1,10 +1,15 @@
 package vkbot
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/AndrewShukhtin/vkbot/event"
 	"github.com/fatih/color"
 	"go.uber.org/zap"
+	"runtime/debug"
+	"sync"
+	"time"
 )
 
 const (
@@ -26,10 +31,10 @@ const (
 )
 
 // HandleFunc alias for event handler function
-type HandleFunc func(event.Event) error
+type HandleFunc func(c *Context) error
 
-var notFoundHandler HandleFunc = func(e event.Event) error {
-	return fmt.Errorf("not implemented event handler for '%s' event", e.Type())
+var notFoundHandler HandleFunc = func(c *Context) error {
+	return fmt.Errorf("not implemented event handler for '%s' event", c.Type())
 }
 
 // BotConfig allows to configure bot
@@ -37,11 +42,40 @@ type BotConfig struct {
 	// Workers number of workers
 	Workers int
 
-	// WorkerBuffer buffer of each worker chan
+	// WorkerBuffer number of queued events per worker, used if QueueSize is zero
 	WorkerBuffer int
 
+	// QueueSize max number of events queued for workers, Workers * WorkerBuffer if zero
+	QueueSize int
+
+	// Overflow policy applied to events received when queue is full, BlockOnOverflow by default
+	Overflow OverflowPolicy
+
+	// SpillDir directory for events spilled by SpillToDisk policy, os.TempDir() if empty
+	SpillDir string
+
 	// Events buffer off events chan
 	Events int
+
+	// Ordering mode of events processing, NoOrdering by default
+	Ordering Ordering
+
+	// HandlerTimeout max duration of event handling, context of handler is cancelled after it,
+	// zero means no limit
+	HandlerTimeout time.Duration
+
+	// HandlerTimeouts overrides HandlerTimeout for event types
+	HandlerTimeouts map[string]time.Duration
+
+	// AutoAnswerCallbacks answers callback button clicks not answered by handler,
+	// so user's client stops button loading
+	AutoAnswerCallbacks bool
+
+	// ShutdownTimeout max time Run waits for received events to be handled, 10 seconds if zero
+	ShutdownTimeout time.Duration
+
+	// HideBanner disables banner printed by Init
+	HideBanner bool
 }
 
 // VkBot structure for handle events from GroupLongPollServer
@@ -50,8 +84,23 @@ type VkBot struct {
 	longPollServer GroupLongPollServer
 	handlers       map[string]HandleFunc
 
-	config     BotConfig
+	middleware     []Middleware
+	typeMiddleware map[string][]Middleware
+	// chains handlers wrapped with middleware on start
+	chains   map[string]HandleFunc
+	notFound HandleFunc
+
+	config BotConfig
+	// mtx guards dispatcher read by QueueStats while bot is starting
+	mtx        sync.Mutex
 	dispatcher *dispatcher
+	inFlight   *inFlight
+	onPanic    PanicHook
+	onError    ErrorHook
+
+	// ctx base context of handlers cancelled on Stop
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	enableBanner bool
 }
@@ -73,6 +122,18 @@ func (bot *VkBot) EventHandler(eventType string, handler HandleFunc) {
 	bot.handlers[eventType] = handler
 }
 
+// OnPanic sets hook called after panic of event handler is recovered and logged,
+// it should be called before Start
+func (bot *VkBot) OnPanic(hook PanicHook) {
+	bot.onPanic = hook
+}
+
+// OnError sets hook receiving errors returned by handlers, including *HandlerTimeoutError,
+// errors are logged by default
+func (bot *VkBot) OnError(hook ErrorHook) {
+	bot.onError = hook
+}
+
 // SetConfig sets configuration of bot
 func (bot *VkBot) SetConfig(cfg BotConfig) {
 	bot.config = cfg
@@ -80,7 +141,7 @@ func (bot *VkBot) SetConfig(cfg BotConfig) {
 
 // Init initializes longPollServer and check correctness of handlers
 func (bot *VkBot) Init() error {
-	if bot.enableBanner {
+	if bot.enableBanner && !bot.config.HideBanner {
 		c := color.New(color.FgBlue, color.Bold)
 		c.Printf(banner, Version)
 	}
@@ -103,93 +164,216 @@ func (bot *VkBot) Init() error {
 
 // Start serves the incoming events
 func (bot *VkBot) Start() {
-	bot.dispatcher = newDispatcher(bot.config.Workers, bot.config.WorkerBuffer)
-	bot.dispatcher.setWorkerFunc(bot.handleEvent)
-	bot.dispatcher.startWorkers()
+	events, err := bot.start()
+	if err != nil {
+		logInternalErrorOr("can not start VkBot", err)
+		return
+	}
+	bot.dispatcher.dispatch(events)
+}
+
+// QueueStats returns metrics of events queue
+func (bot *VkBot) QueueStats() QueueStats {
+	d := bot.loadDispatcher()
+	if d == nil {
+		return QueueStats{}
+	}
+	return d.stats()
+}
+
+// loadDispatcher returns dispatcher of started bot, nil if bot is not started
+func (bot *VkBot) loadDispatcher() *dispatcher {
+	bot.mtx.Lock()
+	defer bot.mtx.Unlock()
+	return bot.dispatcher
+}
+
+// start starts workers and updates loop, it returns chan of received events to dispatch
+func (bot *VkBot) start() (<-chan event.Event, error) {
+	bot.buildChains()
+	d := newDispatcher(bot.config.Workers, bot.config.WorkerBuffer)
+	d.setOrdering(bot.config.Ordering)
+	d.setQueue(bot.config.QueueSize, bot.config.Overflow, bot.config.SpillDir)
+	bot.ctx, bot.cancel = context.WithCancel(context.Background())
+	bot.inFlight = newInFlight()
+	inFlight := bot.inFlight
+	d.setWorkerFunc(func(e event.Event) {
+		defer inFlight.done()
+		bot.handleEvent(e)
+	})
+	d.setDropHook(func(e event.Event) {
+		defer inFlight.done()
+		Logger.Warn("events queue is full, event dropped",
+			zap.String("event_type", e.Type()),
+			zap.String("event_id", e.EventID()))
+	})
+	d.setLostHook(func(count int) {
+		for i := 0; i < count; i++ {
+			inFlight.done()
+		}
+		Logger.Error("spilled events lost", zap.Int("count", count))
+	})
+	d.setPanicHook(bot.onPanic)
+	err := d.startWorkers()
+	// dispatcher is published after its queues are created, so QueueStats can read them
+	bot.mtx.Lock()
+	bot.dispatcher = d
+	bot.mtx.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	done := d.done
 	updatesChan := bot.longPollServer.StartUpdatesLoop()
 	eventsChan := make(chan event.Event, bot.config.Events)
 	go func() {
 		defer close(eventsChan)
+		defer close(inFlight.fed)
 		for u := range updatesChan {
 			for _, e := range u.Events() {
-				eventsChan <- e
+				inFlight.add()
+				select {
+				case eventsChan <- e:
+				case <-done:
+					// workers are stopped, event is abandoned
+					return
+				}
 			}
 		}
 	}()
-	bot.dispatcher.dispatch(eventsChan)
+	return eventsChan, nil
 }
 
-// Stop stops serving incoming events
+// Stop stops serving incoming events immediately, received events are dropped, see Shutdown,
+// it does nothing if bot is not started or already stopped
 func (bot *VkBot) Stop() {
+	d := bot.loadDispatcher()
+	if d == nil {
+		return
+	}
+	if bot.cancel != nil {
+		bot.cancel()
+	}
 	bot.longPollServer.StopUpdatesLoop()
-	bot.dispatcher.stopWorkers(func() { /*dumb hook*/ })
+	d.stopWorkers(func() { /*dumb hook*/ })
 }
 
 func (bot *VkBot) handleEvent(e event.Event) {
-	var handler = notFoundHandler
-	if h, ok := bot.handlers[e.Type()]; ok {
-		handler = h
+	c, cancel, timeout := bot.newEventContext(e)
+	defer cancel()
+	if bot.config.AutoAnswerCallbacks {
+		// deferred, so callback is answered even if handler panics
+		defer func() {
+			if err := bot.autoAnswer(c); err != nil {
+				bot.reportError(c, err)
+			}
+		}()
+	}
+	started := time.Now()
+	w := bot.watch(c, timeout, started)
+	err := bot.chainOf(e.Type())(c)
+	if w.stop() {
+		err = checkTimeout(c, err, timeout, started)
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		// overrun is already reported by watchdog
+		err = nil
 	}
-	err := handler(e)
 	Logger.Info(fmt.Sprintf("handled '%s' event", e.Type()))
 	if err != nil {
-		Logger.Error("something went wrong", zap.Error(err))
+		bot.reportError(c, err)
 	}
 }
 
+// reportError passes error of event handling to error hook or logs it
+func (bot *VkBot) reportError(c *Context, err error) {
+	if bot.onError != nil {
+		bot.onError(c, err)
+		return
+	}
+	Logger.Error("something went wrong",
+		zap.Error(err),
+		zap.String("event_type", c.Type()),
+		zap.String("event_id", c.EventID()))
+}
+
 type workerFunc func(event.Event)
 
+// PanicHook receives event which handler panicked, recovered value and stack trace of panic
+type PanicHook func(e event.Event, recovered interface{}, stack []byte)
+
 type worker struct {
-	workersPool  chan chan event.Event
-	dataChannel  chan event.Event
-	workerBuffer int
-	done         <-chan struct{}
-	workerFunc   workerFunc
+	queue      *eventQueue
+	workerFunc workerFunc
+	panicHook  PanicHook
 }
 
-func newWorker(workersPool chan chan event.Event, workerBuffer int, done <-chan struct{}) *worker {
-	return &worker{
-		workersPool:  workersPool,
-		done:         done,
-		workerBuffer: workerBuffer,
-	}
+func newWorker(queue *eventQueue) *worker {
+	return &worker{queue: queue}
 }
 
 func (w *worker) setWorkerFunc(wf workerFunc) {
 	w.workerFunc = wf
 }
 
+func (w *worker) setPanicHook(hook PanicHook) {
+	w.panicHook = hook
+}
+
+// handle calls workerFunc recovering its panic, so worker keeps serving events
+func (w *worker) handle(e event.Event) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		stack := debug.Stack()
+		eventType, eventID := "", ""
+		if e != nil {
+			eventType, eventID = e.Type(), e.EventID()
+		}
+		Logger.Error("panic while handling event",
+			zap.Any("panic", r),
+			zap.String("event_type", eventType),
+			zap.String("event_id", eventID),
+			zap.ByteString("stack", stack))
+		if w.panicHook != nil {
+			w.panicHook(e, r, stack)
+		}
+	}()
+	w.workerFunc(e)
+}
+
 func (w *worker) run() {
-	w.dataChannel = make(chan event.Event, w.workerBuffer)
 	go func() {
-		defer close(w.dataChannel)
 		for {
-			w.workersPool <- w.dataChannel
-			select {
-			case e, ok := <-w.dataChannel:
-				if !ok {
-					return
-				}
-				w.workerFunc(e)
-			case <-w.done:
+			e, ok := w.queue.pop()
+			if !ok {
 				return
 			}
+			w.handle(e)
 		}
 	}()
 }
 
 type dispatcher struct {
-	workersPool    chan chan event.Event
 	workerPoolSize int
 	workerBuffer   int
 	done           chan struct{}
+	stopOnce       sync.Once
 	workerFunc     workerFunc
+	panicHook      PanicHook
+	dropHook       func(event.Event)
+	lostHook       func(count int)
+	ordering       Ordering
+
+	queueSize int
+	overflow  OverflowPolicy
+	spillDir  string
+	// queues single queue shared by workers or queue of each worker in PeerOrdering mode
+	queues []*eventQueue
 }
 
 func newDispatcher(workersPoolSize int, workerBuffer int) *dispatcher {
-	pool := make(chan chan event.Event, workersPoolSize)
 	return &dispatcher{
-		workersPool:    pool,
 		workerPoolSize: workersPoolSize,
 		workerBuffer:   workerBuffer,
 		done:           make(chan struct{}),
@@ -200,33 +384,120 @@ func (d *dispatcher) setWorkerFunc(wf workerFunc) {
 	d.workerFunc = wf
 }
 
-func (d *dispatcher) startWorkers() {
+func (d *dispatcher) setPanicHook(hook PanicHook) {
+	d.panicHook = hook
+}
+
+func (d *dispatcher) setDropHook(hook func(event.Event)) {
+	d.dropHook = hook
+}
+
+func (d *dispatcher) setLostHook(hook func(count int)) {
+	d.lostHook = hook
+}
+
+func (d *dispatcher) setOrdering(ordering Ordering) {
+	d.ordering = ordering
+}
+
+// setQueue sets size of events queue, zero means Workers * WorkerBuffer, and its overflow policy
+func (d *dispatcher) setQueue(size int, overflow OverflowPolicy, spillDir string) {
+	d.queueSize = size
+	d.overflow = overflow
+	d.spillDir = spillDir
+}
+
+func (d *dispatcher) queueCapacity() int {
+	if d.queueSize > 0 {
+		return d.queueSize
+	}
+	buffer := d.workerBuffer
+	if buffer < 1 {
+		buffer = 1
+	}
+	return d.workerPoolSize * buffer
+}
+
+func (d *dispatcher) newQueue(capacity int) (*eventQueue, error) {
+	q, err := newEventQueue(capacity, d.overflow, d.spillDir, d.done)
+	if err != nil {
+		return nil, err
+	}
+	q.onDrop = d.dropHook
+	q.onLost = d.lostHook
+	d.queues = append(d.queues, q)
+	return q, nil
+}
+
+func (d *dispatcher) startWorkers() error {
 	d.done = make(chan struct{})
+	d.queues = nil
+	var shared *eventQueue
+	if d.ordering != PeerOrdering {
+		var err error
+		if shared, err = d.newQueue(d.queueCapacity()); err != nil {
+			return err
+		}
+	}
 	for i := 0; i < d.workerPoolSize; i++ {
-		w := newWorker(d.workersPool, d.workerBuffer, d.done)
+		q := shared
+		if q == nil {
+			// each worker serves its own queue, so events of one peer are handled in order
+			capacity := d.queueCapacity() / d.workerPoolSize
+			if capacity < 1 {
+				capacity = 1
+			}
+			var err error
+			if q, err = d.newQueue(capacity); err != nil {
+				return err
+			}
+		}
+		w := newWorker(q)
 		w.setWorkerFunc(d.workerFunc)
+		w.setPanicHook(d.panicHook)
 		w.run()
 	}
+	return nil
 }
 
+// stopWorkers stops workers once, repeated calls do nothing
 func (d *dispatcher) stopWorkers(hook hookFunc) {
-	go func() {
-		close(d.done)
-		hook()
-	}()
+	d.stopOnce.Do(func() {
+		go func() {
+			close(d.done)
+			hook()
+		}()
+	})
+}
+
+// stats returns metrics of events queues
+func (d *dispatcher) stats() QueueStats {
+	var s QueueStats
+	for _, q := range d.queues {
+		s = s.add(q.stats())
+	}
+	return s
 }
 
 func (d *dispatcher) dispatch(eventChan <-chan event.Event) {
+	if len(d.queues) == 0 {
+		// no workers to handle events
+		<-d.done
+		return
+	}
 	for {
 		select {
 		case data, ok := <-eventChan:
 			if !ok {
 				return
 			}
-			go func(data event.Event) {
-				dataChannel := <-d.workersPool
-				dataChannel <- data
-			}(data)
+			q := d.queues[0]
+			if len(d.queues) > 1 {
+				q = d.queues[shardOf(data, len(d.queues))]
+			}
+			if !q.push(data) {
+				return
+			}
 		case <-d.done:
 			return
 		}
@@ -4,11 +4,26 @@ import (
 	"fmt"
 	"github.com/AndrewShukhtin/vkbot/event"
 	"github.com/karlseguin/typed"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
 )
 
+// newTestEvent creates event with object, it is shared by tests of all event consumers
+func newTestEvent(t *testing.T, eventType string, object typed.Typed, groupID int, eventID string) event.Event {
+	e, err := event.NewEvent(typed.Typed{
+		"type":     eventType,
+		"object":   object,
+		"group_id": groupID,
+		"event_id": eventID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
 func TestDispatcherAndWorkersHandleAllIncomingEvents(t *testing.T) {
 	d := newDispatcher(2, 0)
 	wg := &sync.WaitGroup{}
@@ -47,7 +62,7 @@ func TestVkBot_SetConfig(t *testing.T) {
 	cfg := BotConfig{Workers: 10, WorkerBuffer: 10, Events: 10}
 	bot.SetConfig(cfg)
 
-	if bot.config != cfg {
+	if !reflect.DeepEqual(bot.config, cfg) {
 		t.Error("configs should be equal")
 	}
 }
@@ -148,25 +163,19 @@ func TestVkBot_handleEvent(t *testing.T) {
 
 		bot := &VkBot{handlers: map[string]HandleFunc{}}
 		if tc.withError {
-			bot.EventHandler(event.MessageNewType, func(_ event.Event) error {
+			bot.EventHandler(event.MessageNewType, func(_ *Context) error {
 				done <- true
 				return fmt.Errorf("test error")
 			})
 		} else {
-			bot.EventHandler(event.MessageNewType, func(_ event.Event) error {
+			bot.EventHandler(event.MessageNewType, func(_ *Context) error {
 				done <- true
 				return nil
 			})
 		}
 
-		e, _ := event.NewEvent(typed.Typed{
-			"type": event.MessageNewType,
-			"object": map[string]interface{}{
-				"test_event_object": 0,
-			},
-			"group_id": 0,
-			"event_id": "test_event_id",
-		})
+		e := newTestEvent(t, event.MessageNewType, typed.Typed{"test_event_object": 0}, 0, "test_event_id")
+		bot.buildChains()
 		go bot.handleEvent(e)
 		<-done
 	}
@@ -303,7 +312,7 @@ func TestVkBot_Start(t *testing.T) {
 	wg := &sync.WaitGroup{}
 	wg.Add(3)
 
-	bot.EventHandler(event.MessageNewType, func(e event.Event) error {
+	bot.EventHandler(event.MessageNewType, func(c *Context) error {
 		wg.Done()
 		return nil
 	})
@@ -311,3 +320,58 @@ func TestVkBot_Start(t *testing.T) {
 	wg.Wait()
 	bot.Stop()
 }
+
+func TestDispatcherRecoversPanic(t *testing.T) {
+	d := newDispatcher(1, 0)
+	handled := make(chan string, 2)
+	panics := make(chan interface{}, 1)
+	d.setWorkerFunc(func(e event.Event) {
+		if e.EventID() == "panic" {
+			panic("test panic")
+		}
+		handled <- e.EventID()
+	})
+	d.setPanicHook(func(e event.Event, recovered interface{}, stack []byte) {
+		if e.EventID() != "panic" || len(stack) == 0 {
+			t.Error("should be passed event and stack")
+		}
+		panics <- recovered
+	})
+	d.startWorkers()
+	defer d.stopWorkers(func() {})
+
+	eventChan := make(chan event.Event)
+	defer close(eventChan)
+	go d.dispatch(eventChan)
+	for _, id := range []string{"panic", "ok"} {
+		eventChan <- newTestEvent(t, event.MessageNewType, typed.Typed{}, 0, id)
+	}
+
+	timer := time.NewTimer(time.Millisecond * 100)
+	select {
+	case r := <-panics:
+		if r != "test panic" {
+			t.Error("should be recovered value", r)
+		}
+	case <-timer.C:
+		t.Fatal("timed out")
+	}
+	select {
+	case id := <-handled:
+		if id != "ok" {
+			t.Error("should be handled next event", id)
+		}
+	case <-timer.C:
+		t.Error("worker should keep serving events after panic")
+	}
+}
+
+func TestVkBot_OnPanic(t *testing.T) {
+	bot := &VkBot{}
+	called := false
+	bot.OnPanic(func(_ event.Event, _ interface{}, _ []byte) { called = true })
+	bot.onPanic(nil, nil, nil)
+	if !called {
+		t.Error("should be set panic hook")
+	}
+}